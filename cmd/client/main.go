@@ -3,15 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pokala15/pcbook/pb"
 	"github.com/pokala15/pcbook/sample"
+	"github.com/pokala15/pcbook/serializer"
+	"github.com/pokala15/pcbook/units"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -28,11 +33,152 @@ func main() {
 
 	client := pb.NewLaptopServiceClient(conn)
 
-	for i := 0; i <= 10; i++ {
-		createLaptop(client, sample.NewLaptop())
+	switch flag.Arg(0) {
+	case "get":
+		getLaptop(client, flag.Arg(1))
+	case "delete":
+		deleteLaptop(client, flag.Arg(1))
+	case "list":
+		listLaptops(client)
+	case "search":
+		searchLaptopByQuery(client, flag.Arg(1))
+	case "images":
+		listImages(client, flag.Arg(1))
+	case "download":
+		downloadImage(client, flag.Arg(1), flag.Arg(2))
+	case "upload":
+		uploadImageResumably(client, flag.Arg(1), flag.Arg(2))
+	default:
+		for i := 0; i <= 10; i++ {
+			createLaptop(client, sample.NewLaptop())
+		}
+		searchLaptop(client)
+		uploadImage(client)
+	}
+}
+
+func getLaptop(client pb.LaptopServiceClient, laptopId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := client.GetLaptop(ctx, &pb.GetLaptopRequest{
+		Id: laptopId,
+	})
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.NotFound {
+			log.Fatalf("laptop doesn't exist with id: %v", laptopId)
+		}
+		log.Fatalf("couldn't get laptop: %v", err)
+	}
+	fmt.Println(serializer.ProtobufToJSON(response.GetLaptop()))
+}
+
+func deleteLaptop(client pb.LaptopServiceClient, laptopId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.DeleteLaptop(ctx, &pb.DeleteLaptopRequest{
+		Id: laptopId,
+	})
+	if err != nil {
+		log.Fatalf("couldn't delete laptop: %v", err)
+	}
+	log.Printf("laptop is deleted with id: %v", laptopId)
+}
+
+func listLaptops(client pb.LaptopServiceClient) {
+	pageToken := ""
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		response, err := client.ListLaptops(ctx, &pb.ListLaptopsRequest{
+			PageToken: pageToken,
+		})
+		cancel()
+		if err != nil {
+			log.Fatalf("couldn't list laptops: %v", err)
+		}
+
+		for _, laptop := range response.GetLaptops() {
+			log.Printf("laptop %v: %v %v, %v RAM", laptop.GetId(), laptop.GetBrand(), laptop.GetName(),
+				units.Format(laptop.GetRam(), units.Default))
+		}
+
+		pageToken = response.GetNextPageToken()
+		if pageToken == "" {
+			return
+		}
+	}
+}
+
+func listImages(client pb.LaptopServiceClient, laptopId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := client.ListImages(ctx, &pb.ListImagesRequest{
+		LaptopId: laptopId,
+	})
+	if err != nil {
+		log.Fatalf("couldn't list images: %v", err)
+	}
+
+	for _, image := range response.GetImages() {
+		primary := ""
+		if image.GetPrimary() {
+			primary = " (primary)"
+		}
+		log.Printf("image %v: %v of %vx%v pixels and %v bytes%v", image.GetId(), image.GetImageType(),
+			image.GetWidth(), image.GetHeight(), image.GetSize(), primary)
+	}
+}
+
+// downloadImage writes the image to path, or to a file named after the image in the
+// current directory if path is empty
+func downloadImage(client pb.LaptopServiceClient, imageId string, path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.DownloadImage(ctx, &pb.DownloadImageRequest{
+		ImageId: imageId,
+	})
+	if err != nil {
+		log.Fatalf("unable to download the image: %v", err)
+	}
+
+	response, err := stream.Recv()
+	if err != nil {
+		log.Fatalf("couldn't receive the image info: %v", err)
+	}
+	info, imageSize := response.GetInfo(), int(response.GetSize())
+	if path == "" {
+		path = imageId + "." + strings.ToLower(info.GetImageType().String())
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("couldn't create the image file: %v", err)
+	}
+	defer file.Close()
+
+	size := 0
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("couldn't receive the image data: %v", err)
+		}
+		n, err := file.Write(response.GetChunkData())
+		if err != nil {
+			log.Fatalf("couldn't write the image file: %v", err)
+		}
+		size += n
 	}
-	searchLaptop(client)
-	uploadImage(client)
+	if size != imageSize {
+		log.Fatalf("image is incomplete: received %v of %v bytes", size, imageSize)
+	}
+	log.Printf("image of laptop %v is downloaded to %v", info.GetLaptopId(), path)
 }
 
 func uploadImage(client pb.LaptopServiceClient) {
@@ -87,10 +233,119 @@ func uploadImage(client pb.LaptopServiceClient) {
 	fmt.Printf("uploaded image with id %v of size %v", response.GetImageId(), response.GetSize())
 }
 
-func searchLaptop(client pb.LaptopServiceClient) {
+// maxUploadAttempts is the number of times an upload is sent before giving up
+const maxUploadAttempts = 5
+
+// uploadImageResumably uploads the image file of the laptop in an upload session, resuming
+// from the data already received when the stream breaks
+func uploadImageResumably(client pb.LaptopServiceClient, laptopId string, imagePath string) {
+	extension := strings.ToUpper(strings.TrimPrefix(filepath.Ext(imagePath), "."))
+	if extension == "JPEG" {
+		extension = "JPG"
+	}
+	imageType := pb.ImageType(pb.ImageType_value[extension])
+	if imageType == pb.ImageType_UNKNOWN {
+		log.Fatalf("unknown image type of file %v", imagePath)
+	}
+	imageData, err := os.ReadFile(imagePath)
+	if err != nil {
+		log.Fatalf("error while reading the file: %v", err)
+	}
+
+	uploadId, err := startUpload(client, laptopId, imageType)
+	if err != nil {
+		log.Fatalf("unable to start the upload: %v", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := sendUploadChunks(client, uploadId, imageData)
+		if err == nil {
+			break
+		}
+		if attempt == maxUploadAttempts {
+			log.Fatalf("unable to upload the image: %v", err)
+		}
+		log.Printf("upload is interrupted, resuming: %v", err)
+		time.Sleep(time.Second)
+	}
+
+	response, err := finishUpload(client, uploadId, imageData)
+	if err != nil {
+		log.Fatalf("unable to finish the upload: %v", err)
+	}
+	fmt.Printf("uploaded image with id %v of size %v", response.GetImageId(), response.GetSize())
+}
+
+// startUpload creates an upload session of an image of the laptop and returns its id
+func startUpload(client pb.LaptopServiceClient, laptopId string, imageType pb.ImageType) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := client.StartUpload(ctx, &pb.StartUploadRequest{
+		Info: &pb.ImageInfo{
+			LaptopId:  laptopId,
+			ImageType: imageType,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.GetUploadId(), nil
+}
+
+// finishUpload stores the received data of the upload session as an image after checking its checksum
+func finishUpload(client pb.LaptopServiceClient, uploadId string, imageData []byte) (*pb.FinishUploadResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	checksum := sha256.Sum256(imageData)
+	return client.FinishUpload(ctx, &pb.FinishUploadRequest{
+		UploadId: uploadId,
+		Sha256:   checksum[:],
+	})
+}
+
+// sendUploadChunks sends the image data that the upload session hasn't received yet
+func sendUploadChunks(client pb.LaptopServiceClient, uploadId string, imageData []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query, err := client.QueryUpload(ctx, &pb.QueryUploadRequest{
+		UploadId: uploadId,
+	})
+	if err != nil {
+		return err
+	}
+	offset := query.GetReceived()
+	if offset > uint64(len(imageData)) {
+		// more than the file was received, so send it all over again
+		offset = 0
+	}
+
+	stream, err := client.UploadImage(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&pb.UploadImageRequest{
+		UploadId: uploadId,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+	for chunk := imageData[offset:]; len(chunk) > 0; {
+		n := min(len(chunk), 1024)
+		if err := stream.Send(&pb.UploadImageRequest{ChunkData: chunk[:n]}); err != nil {
+			break
+		}
+		chunk = chunk[n:]
+	}
+	// the error of a failed send is returned here
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func searchLaptop(client pb.LaptopServiceClient) {
 	filter := &pb.Filter{
 		MaxPriceUsd: 3000,
 		MinCpuCores: 3,
@@ -101,9 +356,22 @@ func searchLaptop(client pb.LaptopServiceClient) {
 		},
 	}
 
-	stream, err := client.SearchLaptop(ctx, &pb.SearchLaptopRequest{
+	streamSearchResults(client, &pb.SearchLaptopRequest{
 		Filter: filter,
 	})
+}
+
+func searchLaptopByQuery(client pb.LaptopServiceClient, query string) {
+	streamSearchResults(client, &pb.SearchLaptopRequest{
+		Query: query,
+	})
+}
+
+func streamSearchResults(client pb.LaptopServiceClient, request *pb.SearchLaptopRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.SearchLaptop(ctx, request)
 
 	if err != nil {
 		log.Fatalf("couldn't search laptop: %v", err)
@@ -1,24 +1,69 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/pokala15/pcbook/pb"
 	"github.com/pokala15/pcbook/service"
 	"google.golang.org/grpc"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
 	port := flag.Int("port", 0, "the server port")
+	dataFolder := flag.String("data", "", "folder to keep laptops in across restarts, in memory only if empty")
+	sqlDsn := flag.String("sql", "", "SQLite data source to keep laptops in, such as file:laptops.db?_txlock=immediate")
+	uploadTtl := flag.Duration("upload-ttl", 24*time.Hour, "time after which uploads left alone are removed")
 	flag.Parse()
+	if *dataFolder != "" && *sqlDsn != "" {
+		log.Fatalf("-data and -sql can't be used together")
+	}
 	log.Printf("server started on port: %v", *port)
 
-	laptopStore := service.NewInMemoryLaptopStore()
-	imageStore := service.NewDiskImageStore("img")
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore)
+	var laptopStore service.LaptopStore = service.NewInMemoryLaptopStore()
+	if *dataFolder != "" {
+		fileStore, err := service.NewFileLaptopStore(*dataFolder)
+		if err != nil {
+			log.Fatalf("can't open laptop store in %v: %v", *dataFolder, err)
+		}
+		// runs once the server is stopped by a signal, so that the log is compacted
+		defer func() {
+			if err := fileStore.Close(); err != nil {
+				log.Printf("can't close laptop store: %v", err)
+			}
+		}()
+		laptopStore = fileStore
+	}
+	if *sqlDsn != "" {
+		db, err := sql.Open("sqlite", *sqlDsn)
+		if err != nil {
+			log.Fatalf("can't open database %v: %v", *sqlDsn, err)
+		}
+		defer db.Close()
+		sqlStore, err := service.NewSQLLaptopStore(db)
+		if err != nil {
+			log.Fatalf("can't open laptop store in %v: %v", *sqlDsn, err)
+		}
+		laptopStore = sqlStore
+	}
+	imageStore, err := service.NewDiskImageStore("img")
+	if err != nil {
+		log.Fatalf("can't open image store: %v", err)
+	}
+	uploadStore, err := service.NewDiskUploadStore("img", *uploadTtl)
+	if err != nil {
+		log.Fatalf("can't open upload store: %v", err)
+	}
+	go removeExpiredUploads(uploadStore)
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, uploadStore)
 	grpcServer := grpc.NewServer()
 
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
@@ -29,8 +74,41 @@ func main() {
 	if err != nil {
 		log.Fatalf("can't start the server on port: %v", *port)
 	}
+	go stopOnSignal(grpcServer)
 	err = grpcServer.Serve(listener)
 	if err != nil {
 		log.Fatalf("can't start the server on port: %v", *port)
 	}
+	log.Printf("server stopped")
+}
+
+// shutdownTimeout is how long running calls are given to end when the server is stopped
+const shutdownTimeout = 10 * time.Second
+
+// stopOnSignal stops the server gracefully on SIGINT or SIGTERM, which makes Serve return
+func stopOnSignal(grpcServer *grpc.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	received := <-signals
+	log.Printf("received %v, stopping the server", received)
+
+	// streams that don't end by themselves, such as stalled searches, are cut
+	timer := time.AfterFunc(shutdownTimeout, grpcServer.Stop)
+	defer timer.Stop()
+	grpcServer.GracefulStop()
+}
+
+// uploadSweepInterval is how often expired uploads are looked for
+const uploadSweepInterval = 10 * time.Minute
+
+func removeExpiredUploads(uploadStore *service.DiskUploadStore) {
+	for now := range time.Tick(uploadSweepInterval) {
+		removed, err := uploadStore.RemoveExpired(now)
+		if err != nil {
+			log.Printf("can't remove expired uploads: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("removed %d expired uploads", removed)
+		}
+	}
 }
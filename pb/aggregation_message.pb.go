@@ -0,0 +1,200 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.3
+// 	protoc        v5.29.3
+// source: aggregation_message.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FacetCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Count         uint32                 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FacetCount) Reset() {
+	*x = FacetCount{}
+	mi := &file_aggregation_message_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FacetCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FacetCount) ProtoMessage() {}
+
+func (x *FacetCount) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregation_message_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FacetCount.ProtoReflect.Descriptor instead.
+func (*FacetCount) Descriptor() ([]byte, []int) {
+	return file_aggregation_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FacetCount) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *FacetCount) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type PriceStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinUsd        float64                `protobuf:"fixed64,1,opt,name=min_usd,json=minUsd,proto3" json:"min_usd,omitempty"`
+	MaxUsd        float64                `protobuf:"fixed64,2,opt,name=max_usd,json=maxUsd,proto3" json:"max_usd,omitempty"`
+	AvgUsd        float64                `protobuf:"fixed64,3,opt,name=avg_usd,json=avgUsd,proto3" json:"avg_usd,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PriceStats) Reset() {
+	*x = PriceStats{}
+	mi := &file_aggregation_message_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PriceStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriceStats) ProtoMessage() {}
+
+func (x *PriceStats) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregation_message_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriceStats.ProtoReflect.Descriptor instead.
+func (*PriceStats) Descriptor() ([]byte, []int) {
+	return file_aggregation_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PriceStats) GetMinUsd() float64 {
+	if x != nil {
+		return x.MinUsd
+	}
+	return 0
+}
+
+func (x *PriceStats) GetMaxUsd() float64 {
+	if x != nil {
+		return x.MaxUsd
+	}
+	return 0
+}
+
+func (x *PriceStats) GetAvgUsd() float64 {
+	if x != nil {
+		return x.AvgUsd
+	}
+	return 0
+}
+
+var File_aggregation_message_proto protoreflect.FileDescriptor
+
+var file_aggregation_message_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x38, 0x0a, 0x0a, 0x46,
+	0x61, 0x63, 0x65, 0x74, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x57, 0x0a, 0x0a, 0x50, 0x72, 0x69, 0x63, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x6d, 0x69, 0x6e, 0x5f, 0x75, 0x73, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x55, 0x73, 0x64, 0x12, 0x17, 0x0a, 0x07,
+	0x6d, 0x61, 0x78, 0x5f, 0x75, 0x73, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6d,
+	0x61, 0x78, 0x55, 0x73, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x76, 0x67, 0x5f, 0x75, 0x73, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x61, 0x76, 0x67, 0x55, 0x73, 0x64, 0x42, 0x05,
+	0x5a, 0x03, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_aggregation_message_proto_rawDescOnce sync.Once
+	file_aggregation_message_proto_rawDescData = file_aggregation_message_proto_rawDesc
+)
+
+func file_aggregation_message_proto_rawDescGZIP() []byte {
+	file_aggregation_message_proto_rawDescOnce.Do(func() {
+		file_aggregation_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_aggregation_message_proto_rawDescData)
+	})
+	return file_aggregation_message_proto_rawDescData
+}
+
+var file_aggregation_message_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_aggregation_message_proto_goTypes = []any{
+	(*FacetCount)(nil), // 0: FacetCount
+	(*PriceStats)(nil), // 1: PriceStats
+}
+var file_aggregation_message_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_aggregation_message_proto_init() }
+func file_aggregation_message_proto_init() {
+	if File_aggregation_message_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_aggregation_message_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_aggregation_message_proto_goTypes,
+		DependencyIndexes: file_aggregation_message_proto_depIdxs,
+		MessageInfos:      file_aggregation_message_proto_msgTypes,
+	}.Build()
+	File_aggregation_message_proto = out.File
+	file_aggregation_message_proto_rawDesc = nil
+	file_aggregation_message_proto_goTypes = nil
+	file_aggregation_message_proto_depIdxs = nil
+}
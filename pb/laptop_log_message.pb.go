@@ -0,0 +1,226 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.3
+// 	protoc        v5.29.3
+// source: laptop_log_message.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// LaptopLogRecord is a change in the write-ahead log of the file laptop store
+type LaptopLogRecord struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Change:
+	//
+	//	*LaptopLogRecord_Put
+	//	*LaptopLogRecord_DeleteId
+	Change        isLaptopLogRecord_Change `protobuf_oneof:"change"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LaptopLogRecord) Reset() {
+	*x = LaptopLogRecord{}
+	mi := &file_laptop_log_message_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LaptopLogRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LaptopLogRecord) ProtoMessage() {}
+
+func (x *LaptopLogRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_laptop_log_message_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LaptopLogRecord.ProtoReflect.Descriptor instead.
+func (*LaptopLogRecord) Descriptor() ([]byte, []int) {
+	return file_laptop_log_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LaptopLogRecord) GetChange() isLaptopLogRecord_Change {
+	if x != nil {
+		return x.Change
+	}
+	return nil
+}
+
+func (x *LaptopLogRecord) GetPut() *Laptop {
+	if x != nil {
+		if x, ok := x.Change.(*LaptopLogRecord_Put); ok {
+			return x.Put
+		}
+	}
+	return nil
+}
+
+func (x *LaptopLogRecord) GetDeleteId() string {
+	if x != nil {
+		if x, ok := x.Change.(*LaptopLogRecord_DeleteId); ok {
+			return x.DeleteId
+		}
+	}
+	return ""
+}
+
+type isLaptopLogRecord_Change interface {
+	isLaptopLogRecord_Change()
+}
+
+type LaptopLogRecord_Put struct {
+	// the whole laptop after it was saved or updated
+	Put *Laptop `protobuf:"bytes,1,opt,name=put,proto3,oneof"`
+}
+
+type LaptopLogRecord_DeleteId struct {
+	DeleteId string `protobuf:"bytes,2,opt,name=delete_id,json=deleteId,proto3,oneof"`
+}
+
+func (*LaptopLogRecord_Put) isLaptopLogRecord_Change() {}
+
+func (*LaptopLogRecord_DeleteId) isLaptopLogRecord_Change() {}
+
+// LaptopSnapshot holds every laptop of the file laptop store at the time of a compaction
+type LaptopSnapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Laptops       []*Laptop              `protobuf:"bytes,1,rep,name=laptops,proto3" json:"laptops,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LaptopSnapshot) Reset() {
+	*x = LaptopSnapshot{}
+	mi := &file_laptop_log_message_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LaptopSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LaptopSnapshot) ProtoMessage() {}
+
+func (x *LaptopSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_laptop_log_message_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LaptopSnapshot.ProtoReflect.Descriptor instead.
+func (*LaptopSnapshot) Descriptor() ([]byte, []int) {
+	return file_laptop_log_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LaptopSnapshot) GetLaptops() []*Laptop {
+	if x != nil {
+		return x.Laptops
+	}
+	return nil
+}
+
+var File_laptop_log_message_proto protoreflect.FileDescriptor
+
+var file_laptop_log_message_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x6c, 0x6f, 0x67, 0x5f, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x14, 0x6c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x22, 0x57, 0x0a, 0x0f, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x4c, 0x6f, 0x67, 0x52, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x12, 0x1b, 0x0a, 0x03, 0x70, 0x75, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x07, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x48, 0x00, 0x52, 0x03, 0x70, 0x75, 0x74,
+	0x12, 0x1d, 0x0a, 0x09, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x08, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x49, 0x64, 0x42,
+	0x08, 0x0a, 0x06, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x22, 0x33, 0x0a, 0x0e, 0x4c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x21, 0x0a, 0x07, 0x6c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x07, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x42, 0x05,
+	0x5a, 0x03, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_laptop_log_message_proto_rawDescOnce sync.Once
+	file_laptop_log_message_proto_rawDescData = file_laptop_log_message_proto_rawDesc
+)
+
+func file_laptop_log_message_proto_rawDescGZIP() []byte {
+	file_laptop_log_message_proto_rawDescOnce.Do(func() {
+		file_laptop_log_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_laptop_log_message_proto_rawDescData)
+	})
+	return file_laptop_log_message_proto_rawDescData
+}
+
+var file_laptop_log_message_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_laptop_log_message_proto_goTypes = []any{
+	(*LaptopLogRecord)(nil), // 0: LaptopLogRecord
+	(*LaptopSnapshot)(nil),  // 1: LaptopSnapshot
+	(*Laptop)(nil),          // 2: Laptop
+}
+var file_laptop_log_message_proto_depIdxs = []int32{
+	2, // 0: LaptopLogRecord.put:type_name -> Laptop
+	2, // 1: LaptopSnapshot.laptops:type_name -> Laptop
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_laptop_log_message_proto_init() }
+func file_laptop_log_message_proto_init() {
+	if File_laptop_log_message_proto != nil {
+		return
+	}
+	file_laptop_message_proto_init()
+	file_laptop_log_message_proto_msgTypes[0].OneofWrappers = []any{
+		(*LaptopLogRecord_Put)(nil),
+		(*LaptopLogRecord_DeleteId)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_laptop_log_message_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_laptop_log_message_proto_goTypes,
+		DependencyIndexes: file_laptop_log_message_proto_depIdxs,
+		MessageInfos:      file_laptop_log_message_proto_msgTypes,
+	}.Build()
+	File_laptop_log_message_proto = out.File
+	file_laptop_log_message_proto_rawDesc = nil
+	file_laptop_log_message_proto_goTypes = nil
+	file_laptop_log_message_proto_depIdxs = nil
+}
@@ -0,0 +1,257 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.3
+// 	protoc        v5.29.3
+// source: sort_message.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Sort_Field int32
+
+const (
+	Sort_UNKNOWN      Sort_Field = 0
+	Sort_PRICE        Sort_Field = 1
+	Sort_CPU_GHZ      Sort_Field = 2
+	Sort_RAM          Sort_Field = 3
+	Sort_RELEASE_YEAR Sort_Field = 4
+	Sort_WEIGHT       Sort_Field = 5
+	Sort_UPDATED_AT   Sort_Field = 6
+)
+
+// Enum value maps for Sort_Field.
+var (
+	Sort_Field_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "PRICE",
+		2: "CPU_GHZ",
+		3: "RAM",
+		4: "RELEASE_YEAR",
+		5: "WEIGHT",
+		6: "UPDATED_AT",
+	}
+	Sort_Field_value = map[string]int32{
+		"UNKNOWN":      0,
+		"PRICE":        1,
+		"CPU_GHZ":      2,
+		"RAM":          3,
+		"RELEASE_YEAR": 4,
+		"WEIGHT":       5,
+		"UPDATED_AT":   6,
+	}
+)
+
+func (x Sort_Field) Enum() *Sort_Field {
+	p := new(Sort_Field)
+	*p = x
+	return p
+}
+
+func (x Sort_Field) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sort_Field) Descriptor() protoreflect.EnumDescriptor {
+	return file_sort_message_proto_enumTypes[0].Descriptor()
+}
+
+func (Sort_Field) Type() protoreflect.EnumType {
+	return &file_sort_message_proto_enumTypes[0]
+}
+
+func (x Sort_Field) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sort_Field.Descriptor instead.
+func (Sort_Field) EnumDescriptor() ([]byte, []int) {
+	return file_sort_message_proto_rawDescGZIP(), []int{0, 0}
+}
+
+type Sort_Direction int32
+
+const (
+	Sort_ASCENDING  Sort_Direction = 0
+	Sort_DESCENDING Sort_Direction = 1
+)
+
+// Enum value maps for Sort_Direction.
+var (
+	Sort_Direction_name = map[int32]string{
+		0: "ASCENDING",
+		1: "DESCENDING",
+	}
+	Sort_Direction_value = map[string]int32{
+		"ASCENDING":  0,
+		"DESCENDING": 1,
+	}
+)
+
+func (x Sort_Direction) Enum() *Sort_Direction {
+	p := new(Sort_Direction)
+	*p = x
+	return p
+}
+
+func (x Sort_Direction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sort_Direction) Descriptor() protoreflect.EnumDescriptor {
+	return file_sort_message_proto_enumTypes[1].Descriptor()
+}
+
+func (Sort_Direction) Type() protoreflect.EnumType {
+	return &file_sort_message_proto_enumTypes[1]
+}
+
+func (x Sort_Direction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sort_Direction.Descriptor instead.
+func (Sort_Direction) EnumDescriptor() ([]byte, []int) {
+	return file_sort_message_proto_rawDescGZIP(), []int{0, 1}
+}
+
+type Sort struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         Sort_Field             `protobuf:"varint,1,opt,name=field,proto3,enum=Sort_Field" json:"field,omitempty"`
+	Direction     Sort_Direction         `protobuf:"varint,2,opt,name=direction,proto3,enum=Sort_Direction" json:"direction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sort) Reset() {
+	*x = Sort{}
+	mi := &file_sort_message_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sort) ProtoMessage() {}
+
+func (x *Sort) ProtoReflect() protoreflect.Message {
+	mi := &file_sort_message_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sort.ProtoReflect.Descriptor instead.
+func (*Sort) Descriptor() ([]byte, []int) {
+	return file_sort_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sort) GetField() Sort_Field {
+	if x != nil {
+		return x.Field
+	}
+	return Sort_UNKNOWN
+}
+
+func (x *Sort) GetDirection() Sort_Direction {
+	if x != nil {
+		return x.Direction
+	}
+	return Sort_ASCENDING
+}
+
+var File_sort_message_proto protoreflect.FileDescriptor
+
+var file_sort_message_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x73, 0x6f, 0x72, 0x74, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x22, 0xe9, 0x01, 0x0a, 0x04, 0x53, 0x6f, 0x72, 0x74, 0x12, 0x21, 0x0a,
+	0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0b, 0x2e, 0x53,
+	0x6f, 0x72, 0x74, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64,
+	0x12, 0x2d, 0x0a, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x0f, 0x2e, 0x53, 0x6f, 0x72, 0x74, 0x2e, 0x44, 0x69, 0x72, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22,
+	0x63, 0x0a, 0x05, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e,
+	0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x50, 0x52, 0x49, 0x43, 0x45, 0x10, 0x01,
+	0x12, 0x0b, 0x0a, 0x07, 0x43, 0x50, 0x55, 0x5f, 0x47, 0x48, 0x5a, 0x10, 0x02, 0x12, 0x07, 0x0a,
+	0x03, 0x52, 0x41, 0x4d, 0x10, 0x03, 0x12, 0x10, 0x0a, 0x0c, 0x52, 0x45, 0x4c, 0x45, 0x41, 0x53,
+	0x45, 0x5f, 0x59, 0x45, 0x41, 0x52, 0x10, 0x04, 0x12, 0x0a, 0x0a, 0x06, 0x57, 0x45, 0x49, 0x47,
+	0x48, 0x54, 0x10, 0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x55, 0x50, 0x44, 0x41, 0x54, 0x45, 0x44, 0x5f,
+	0x41, 0x54, 0x10, 0x06, 0x22, 0x2a, 0x0a, 0x09, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x0d, 0x0a, 0x09, 0x41, 0x53, 0x43, 0x45, 0x4e, 0x44, 0x49, 0x4e, 0x47, 0x10, 0x00,
+	0x12, 0x0e, 0x0a, 0x0a, 0x44, 0x45, 0x53, 0x43, 0x45, 0x4e, 0x44, 0x49, 0x4e, 0x47, 0x10, 0x01,
+	0x42, 0x05, 0x5a, 0x03, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_sort_message_proto_rawDescOnce sync.Once
+	file_sort_message_proto_rawDescData = file_sort_message_proto_rawDesc
+)
+
+func file_sort_message_proto_rawDescGZIP() []byte {
+	file_sort_message_proto_rawDescOnce.Do(func() {
+		file_sort_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_sort_message_proto_rawDescData)
+	})
+	return file_sort_message_proto_rawDescData
+}
+
+var file_sort_message_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_sort_message_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_sort_message_proto_goTypes = []any{
+	(Sort_Field)(0),     // 0: Sort.Field
+	(Sort_Direction)(0), // 1: Sort.Direction
+	(*Sort)(nil),        // 2: Sort
+}
+var file_sort_message_proto_depIdxs = []int32{
+	0, // 0: Sort.field:type_name -> Sort.Field
+	1, // 1: Sort.direction:type_name -> Sort.Direction
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_sort_message_proto_init() }
+func file_sort_message_proto_init() {
+	if File_sort_message_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_sort_message_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_sort_message_proto_goTypes,
+		DependencyIndexes: file_sort_message_proto_depIdxs,
+		EnumInfos:         file_sort_message_proto_enumTypes,
+		MessageInfos:      file_sort_message_proto_msgTypes,
+	}.Build()
+	File_sort_message_proto = out.File
+	file_sort_message_proto_rawDesc = nil
+	file_sort_message_proto_goTypes = nil
+	file_sort_message_proto_depIdxs = nil
+}
@@ -0,0 +1,281 @@
+package query
+
+import (
+	"cmp"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+// compilers of the supported fields, each turning an operator and a value into a predicate
+var fields = map[string]func(t token) (Predicate, error){
+	"brand": textField(func(laptop *pb.Laptop) []string {
+		return []string{laptop.GetBrand()}
+	}),
+	"name": textField(func(laptop *pb.Laptop) []string {
+		return []string{laptop.GetName()}
+	}),
+	"cpu": textField(func(laptop *pb.Laptop) []string {
+		return []string{laptop.GetCpu().GetName(), laptop.GetCpu().GetBrand()}
+	}),
+	"gpu": textField(func(laptop *pb.Laptop) []string {
+		values := []string{}
+		for _, gpu := range laptop.GetGpus() {
+			values = append(values, gpu.GetName(), gpu.GetBrand())
+		}
+		return values
+	}),
+	"price": numberField(func(laptop *pb.Laptop) float64 {
+		return laptop.GetPriceUsd()
+	}),
+	"cores": numberField(func(laptop *pb.Laptop) float64 {
+		return float64(laptop.GetCpu().GetNumberCores())
+	}),
+	"threads": numberField(func(laptop *pb.Laptop) float64 {
+		return float64(laptop.GetCpu().GetNumberThreads())
+	}),
+	"ghz": numberField(func(laptop *pb.Laptop) float64 {
+		return laptop.GetCpu().GetMinGhz()
+	}),
+	"year": numberField(func(laptop *pb.Laptop) float64 {
+		return float64(laptop.GetReleaseYear())
+	}),
+	"screen": numberField(func(laptop *pb.Laptop) float64 {
+		return float64(laptop.GetScreen().GetSizeInch())
+	}),
+	"weight": weightField,
+	"ram": memoryField(func(laptop *pb.Laptop) uint64 {
+		return units.SaturatedBits(laptop.GetRam())
+	}),
+	"ssd": memoryField(func(laptop *pb.Laptop) uint64 {
+		return storageBits(laptop, pb.Storage_SSD)
+	}),
+	"hdd": memoryField(func(laptop *pb.Laptop) uint64 {
+		return storageBits(laptop, pb.Storage_HDD)
+	}),
+	"panel": enumField(pb.Screen_Panel_value, func(laptop *pb.Laptop) int32 {
+		return int32(laptop.GetScreen().GetPanel())
+	}),
+	"layout": enumField(pb.Keyboard_Layout_value, func(laptop *pb.Laptop) int32 {
+		return int32(laptop.GetKeyboard().GetLayout())
+	}),
+	"multitouch": boolField(func(laptop *pb.Laptop) bool {
+		return laptop.GetScreen().GetMultitouch()
+	}),
+	"backlit": boolField(func(laptop *pb.Laptop) bool {
+		return laptop.GetKeyboard().GetBacklit()
+	}),
+}
+
+func compileTerm(t token) (Predicate, error) {
+	compile, ok := fields[t.field]
+	if !ok {
+		return nil, errorf(t.position, "unknown field %q", t.field)
+	}
+	return compile(t)
+}
+
+// textField matches case insensitively against any of the values, where * in the query matches any text
+func textField(get func(laptop *pb.Laptop) []string) func(t token) (Predicate, error) {
+	return func(t token) (Predicate, error) {
+		pattern := strings.ToLower(t.value)
+		matches := func(laptop *pb.Laptop) bool {
+			for _, value := range get(laptop) {
+				if matchWildcard(pattern, strings.ToLower(value)) {
+					return true
+				}
+			}
+			return false
+		}
+
+		switch t.op {
+		case ":", "=":
+			return matches, nil
+		case "!=":
+			return func(laptop *pb.Laptop) bool {
+				return !matches(laptop)
+			}, nil
+		default:
+			return nil, errorf(t.opPosition, "operator %s is not supported by field %s", t.op, t.field)
+		}
+	}
+}
+
+func numberField(get func(laptop *pb.Laptop) float64) func(t token) (Predicate, error) {
+	return func(t token) (Predicate, error) {
+		value, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, errorf(t.valuePosition, "field %s expects a number, found %q", t.field, t.value)
+		}
+		return compare(t, func(laptop *pb.Laptop) int {
+			return cmp.Compare(get(laptop), value)
+		})
+	}
+}
+
+// weightField accepts a kg or lb suffix and defaults to kilograms
+func weightField(t token) (Predicate, error) {
+	text := strings.ToLower(t.value)
+	factor := 1.0
+	if strings.HasSuffix(text, "lb") {
+		text = strings.TrimSuffix(text, "lb")
+		factor = units.KilogramsPerPound
+	} else {
+		text = strings.TrimSuffix(text, "kg")
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, errorf(t.valuePosition, "field %s expects a weight such as 1.5kg or 3lb, found %q", t.field, t.value)
+	}
+	value *= factor
+
+	return compare(t, func(laptop *pb.Laptop) int {
+		return cmp.Compare(units.WeightKg(laptop), value)
+	})
+}
+
+var memoryUnits = []struct {
+	suffix string
+	unit   pb.Memory_Unit
+}{
+	{"tb", pb.Memory_TERABYTE},
+	{"gb", pb.Memory_GIGABYTE},
+	{"mb", pb.Memory_MEGABYTE},
+	{"kb", pb.Memory_KILOBYTE},
+	{"b", pb.Memory_BYTE},
+}
+
+// memoryField accepts a B, KB, MB, GB or TB suffix and defaults to gigabytes
+func memoryField(get func(laptop *pb.Laptop) uint64) func(t token) (Predicate, error) {
+	return func(t token) (Predicate, error) {
+		text := strings.ToLower(t.value)
+		memory := &pb.Memory{Unit: pb.Memory_GIGABYTE}
+		for _, u := range memoryUnits {
+			if strings.HasSuffix(text, u.suffix) {
+				text = strings.TrimSuffix(text, u.suffix)
+				memory.Unit = u.unit
+				break
+			}
+		}
+
+		value, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, errorf(t.valuePosition, "field %s expects a size such as 16GB, found %q", t.field, t.value)
+		}
+		memory.Value = value
+		bits := units.SaturatedBits(memory)
+
+		return compare(t, func(laptop *pb.Laptop) int {
+			return cmp.Compare(get(laptop), bits)
+		})
+	}
+}
+
+func enumField(values map[string]int32, get func(laptop *pb.Laptop) int32) func(t token) (Predicate, error) {
+	return func(t token) (Predicate, error) {
+		value, ok := values[strings.ToUpper(t.value)]
+		if !ok {
+			return nil, errorf(t.valuePosition, "unknown %s %q", t.field, t.value)
+		}
+
+		switch t.op {
+		case ":", "=":
+			return func(laptop *pb.Laptop) bool {
+				return get(laptop) == value
+			}, nil
+		case "!=":
+			return func(laptop *pb.Laptop) bool {
+				return get(laptop) != value
+			}, nil
+		default:
+			return nil, errorf(t.opPosition, "operator %s is not supported by field %s", t.op, t.field)
+		}
+	}
+}
+
+func boolField(get func(laptop *pb.Laptop) bool) func(t token) (Predicate, error) {
+	return func(t token) (Predicate, error) {
+		var value bool
+		switch strings.ToLower(t.value) {
+		case "true", "yes":
+			value = true
+		case "false", "no":
+			value = false
+		default:
+			return nil, errorf(t.valuePosition, "field %s expects true or false, found %q", t.field, t.value)
+		}
+
+		switch t.op {
+		case ":", "=":
+			return func(laptop *pb.Laptop) bool {
+				return get(laptop) == value
+			}, nil
+		case "!=":
+			return func(laptop *pb.Laptop) bool {
+				return get(laptop) != value
+			}, nil
+		default:
+			return nil, errorf(t.opPosition, "operator %s is not supported by field %s", t.op, t.field)
+		}
+	}
+}
+
+// compare turns an ordering of the laptop against the query value into a predicate for the term's operator
+func compare(t token, order func(laptop *pb.Laptop) int) (Predicate, error) {
+	var accept func(result int) bool
+	switch t.op {
+	case ":", "=":
+		accept = func(result int) bool { return result == 0 }
+	case "!=":
+		accept = func(result int) bool { return result != 0 }
+	case "<":
+		accept = func(result int) bool { return result < 0 }
+	case "<=":
+		accept = func(result int) bool { return result <= 0 }
+	case ">":
+		accept = func(result int) bool { return result > 0 }
+	case ">=":
+		accept = func(result int) bool { return result >= 0 }
+	}
+	return func(laptop *pb.Laptop) bool {
+		return accept(order(laptop))
+	}, nil
+}
+
+// matchWildcard reports whether text matches the pattern, where * matches any sequence of characters
+func matchWildcard(pattern string, text string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == text
+	}
+
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(text, part)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(part):]
+	}
+	return strings.HasSuffix(text, parts[len(parts)-1])
+}
+
+func storageBits(laptop *pb.Laptop, driver pb.Storage_Driver) uint64 {
+	capacity := &pb.Memory{}
+	for _, storage := range laptop.GetStorages() {
+		if storage.GetDriver() == driver {
+			var err error
+			if capacity, err = units.Add(capacity, storage.GetMemory(), units.Default); err != nil {
+				return math.MaxUint64
+			}
+		}
+	}
+	return units.SaturatedBits(capacity)
+}
@@ -0,0 +1,129 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLeftParen
+	tokenRightParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTerm
+)
+
+// token is either a parenthesis, a keyword or a whole `field op value` term
+type token struct {
+	kind     tokenKind
+	position int
+
+	field         string
+	op            string
+	opPosition    int
+	value         string
+	valuePosition int
+}
+
+var operators = []string{">=", "<=", "!=", ":", "=", "<", ">"}
+
+func tokenize(input string) ([]token, error) {
+	tokens := []token{}
+	position := 0
+
+	for {
+		for position < len(input) && unicode.IsSpace(rune(input[position])) {
+			position++
+		}
+		if position == len(input) {
+			return append(tokens, token{kind: tokenEOF, position: position}), nil
+		}
+
+		switch input[position] {
+		case '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, position: position})
+			position++
+			continue
+		case ')':
+			tokens = append(tokens, token{kind: tokenRightParen, position: position})
+			position++
+			continue
+		}
+
+		start := position
+		for position < len(input) && isFieldChar(input[position]) {
+			position++
+		}
+		word := input[start:position]
+		if word == "" {
+			return nil, errorf(start, "expected field name, found %q", input[start])
+		}
+
+		op := ""
+		for _, candidate := range operators {
+			if strings.HasPrefix(input[position:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd, position: start})
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr, position: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot, position: start})
+			default:
+				return nil, errorf(position, "expected operator after field %q", word)
+			}
+			continue
+		}
+
+		opPosition := position
+		position += len(op)
+		value, end, err := readValue(input, position)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token{
+			kind:          tokenTerm,
+			position:      start,
+			field:         strings.ToLower(word),
+			op:            op,
+			opPosition:    opPosition,
+			value:         value,
+			valuePosition: position,
+		})
+		position = end
+	}
+}
+
+// readValue reads a quoted or bare value starting at position
+// and returns it with the position right after it
+func readValue(input string, position int) (string, int, error) {
+	if position < len(input) && input[position] == '"' {
+		end := strings.IndexByte(input[position+1:], '"')
+		if end < 0 {
+			return "", 0, errorf(position, "unterminated quoted value")
+		}
+		return input[position+1 : position+1+end], position + end + 2, nil
+	}
+
+	end := position
+	for end < len(input) && !unicode.IsSpace(rune(input[end])) && input[end] != '(' && input[end] != ')' {
+		end++
+	}
+	if end == position {
+		return "", 0, errorf(position, "expected value")
+	}
+	return input[position:end], end, nil
+}
+
+func isFieldChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
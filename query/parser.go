@@ -0,0 +1,195 @@
+// Package query compiles textual laptop queries such as
+// `brand:Dell price<2000 ram>=16GB gpu:RTX*` into predicates.
+//
+// Terms are written as field, operator and value, and are combined with
+// AND (also implied between adjacent terms), OR, NOT and parentheses.
+// AND binds tighter than OR.
+package query
+
+import (
+	"fmt"
+
+	"github.com/pokala15/pcbook/pb"
+)
+
+// MaxDepth is the deepest nesting of parentheses and NOT a query may have,
+// so that parsing and matching never run out of stack
+const MaxDepth = 64
+
+// Predicate reports whether a laptop matches a query
+type Predicate func(laptop *pb.Laptop) bool
+
+// Error is a syntax or type error in a query
+type Error struct {
+	Position int // zero based byte offset in the query
+	Message  string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", err.Message, err.Position)
+}
+
+func errorf(position int, format string, args ...any) *Error {
+	return &Error{
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Parse compiles the query into a predicate. Errors are of type *Error.
+func Parse(input string) (Predicate, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, errorf(next.position, "unexpected %s", describe(next))
+	}
+	return predicate, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	depth  int // parentheses and NOT being parsed
+}
+
+// enter counts a nested parenthesis or NOT, which the caller must leave once parsed
+func (p *parser) enter(t token) error {
+	if p.depth == MaxDepth {
+		return errorf(t.position, "query is nested deeper than %d levels", MaxDepth)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or(left, right)
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Predicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.take()
+		case tokenNot, tokenLeftParen, tokenTerm:
+			// adjacent terms are implicitly combined with AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = and(left, right)
+	}
+}
+
+func (p *parser) parseNot() (Predicate, error) {
+	if p.peek().kind == tokenNot {
+		if err := p.enter(p.take()); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(laptop *pb.Laptop) bool {
+			return !operand(laptop)
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Predicate, error) {
+	t := p.take()
+	switch t.kind {
+	case tokenLeftParen:
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokenRightParen {
+			return nil, errorf(closing.position, "expected ) to close ( at position %d, found %s", t.position, describe(closing))
+		}
+		return predicate, nil
+	case tokenTerm:
+		return compileTerm(t)
+	default:
+		return nil, errorf(t.position, "expected term, found %s", describe(t))
+	}
+}
+
+func and(left Predicate, right Predicate) Predicate {
+	return func(laptop *pb.Laptop) bool {
+		return left(laptop) && right(laptop)
+	}
+}
+
+func or(left Predicate, right Predicate) Predicate {
+	return func(laptop *pb.Laptop) bool {
+		return left(laptop) || right(laptop)
+	}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenLeftParen:
+		return "("
+	case tokenRightParen:
+		return ")"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	default:
+		return fmt.Sprintf("term %s%s%s", t.field, t.op, t.value)
+	}
+}
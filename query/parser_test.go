@@ -0,0 +1,119 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLaptop() *pb.Laptop {
+	return &pb.Laptop{
+		Brand: "Dell",
+		Name:  "XPS",
+		Cpu:   &pb.CPU{Brand: "intel", Name: "Core i7-9750H", NumberCores: 6, NumberThreads: 12, MinGhz: 2.6},
+		Ram:   &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE},
+		Gpus:  []*pb.GPU{{Brand: "NVIDIA", Name: "RTX 2070"}},
+		Storages: []*pb.Storage{
+			{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 512, Unit: pb.Memory_GIGABYTE}},
+		},
+		Screen:      &pb.Screen{SizeInch: 15.6, Panel: pb.Screen_OLED, Multitouch: true},
+		Keyboard:    &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: false},
+		Weight:      &pb.Laptop_WeightLb{WeightLb: 4},
+		PriceUsd:    1899,
+		ReleaseYear: 2019,
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		query string
+		match bool
+	}{
+		{`brand:Dell price<2000 ram>=16GB gpu:RTX*`, true},
+		{`brand:dell AND price<1800`, false},
+		{`brand:Apple OR brand:Dell`, true},
+		{`brand:Apple OR brand:Lenovo`, false},
+		{`NOT brand:Apple`, true},
+		{`brand:Apple OR (cores>=6 AND ghz>2.5)`, true},
+		{`(brand:Apple OR brand:Lenovo) cores>=6`, false},
+		{`name:"XPS"`, true},
+		{`cpu:*i7*`, true},
+		{`gpu:nvidia`, true},
+		{`ram>16GB`, false},
+		{`ram=16384MB`, true},
+		{`ssd>=512 hdd<1`, true},
+		{`weight<2kg weight>=4lb`, true},
+		{`screen>15 panel:OLED multitouch:yes`, true},
+		{`layout:qwerty backlit:true`, false},
+		{`year!=2019`, false},
+		{`threads=12`, true},
+	}
+
+	laptop := newTestLaptop()
+	for _, tc := range testCases {
+		predicate, err := Parse(tc.query)
+		require.NoError(t, err, tc.query)
+		require.Equal(t, tc.match, predicate(laptop), tc.query)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		query    string
+		position int
+	}{
+		{`colour:red`, 0},
+		{`brand:Dell price`, 16},
+		{`price<cheap`, 6},
+		{`brand<Dell`, 5},
+		{`(brand:Dell`, 11},
+		{`brand:Dell)`, 10},
+		{`brand:Dell OR`, 13},
+		{`panel:TN`, 6},
+		{`ssd>=0.5TB`, 5},
+		{`name:"XPS`, 5},
+		{`brand:`, 6},
+		{`*`, 0},
+	}
+
+	for _, tc := range testCases {
+		_, err := Parse(tc.query)
+		require.Error(t, err, tc.query)
+
+		var queryErr *Error
+		require.ErrorAs(t, err, &queryErr)
+		require.Equal(t, tc.position, queryErr.Position, "%s: %v", tc.query, err)
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	t.Parallel()
+
+	nested := strings.Repeat("(", MaxDepth) + "price<1" + strings.Repeat(")", MaxDepth)
+	_, err := Parse(nested)
+	require.NoError(t, err)
+	_, err = Parse(strings.Repeat("NOT ", MaxDepth) + "price<1")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		query    string
+		position int
+	}{
+		{strings.Repeat("(", 2e6) + "price<1" + strings.Repeat(")", 2e6), MaxDepth},
+		{strings.Repeat("NOT ", 2e6) + "price<1", 4 * MaxDepth},
+		{"(" + nested + ")", MaxDepth},
+		{"price<1 OR NOT " + nested, 15 + MaxDepth - 1},
+	}
+	for _, tc := range testCases {
+		_, err := Parse(tc.query)
+		var queryErr *Error
+		require.ErrorAs(t, err, &queryErr)
+		require.Equal(t, tc.position, queryErr.Position, err.Error())
+	}
+}
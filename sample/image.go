@@ -0,0 +1,43 @@
+package sample
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+
+	"github.com/pokala15/pcbook/pb"
+)
+
+// NewImage returns an image of random pixels encoded as the image type.
+// It panics for WEBP, which can be decoded but not encoded.
+func NewImage(imageType pb.ImageType, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(rand.Intn(256)), G: uint8(rand.Intn(256)), B: uint8(rand.Intn(256)), A: 255})
+		}
+	}
+
+	var buffer bytes.Buffer
+	var err error
+	switch imageType {
+	case pb.ImageType_JPG:
+		err = jpeg.Encode(&buffer, img, nil)
+	case pb.ImageType_PNG:
+		err = png.Encode(&buffer, img)
+	case pb.ImageType_GIF:
+		err = gif.Encode(&buffer, img, &gif.Options{NumColors: len(palette.Plan9)})
+	default:
+		panic(fmt.Sprintf("can't encode %v images", imageType))
+	}
+	if err != nil {
+		panic(fmt.Sprintf("can't encode %v image: %v", imageType, err))
+	}
+	return buffer.Bytes()
+}
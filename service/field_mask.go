@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// fields of a laptop that are maintained by the store and can't be updated by clients
+var immutableLaptopFields = map[string]bool{
+	"id":         true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// applyFieldMask copies the fields listed in the mask from src to dst.
+// An empty mask copies every field that is not immutable.
+func applyFieldMask(dst proto.Message, src proto.Message, mask *fieldmaskpb.FieldMask) error {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		fields := src.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			name := string(fields.Get(i).Name())
+			if !immutableLaptopFields[name] {
+				paths = append(paths, name)
+			}
+		}
+	} else if !mask.IsValid(src) {
+		return fmt.Errorf("%w: %v", ErrInvalidFieldMask, paths)
+	}
+
+	for _, path := range paths {
+		if immutableLaptopFields[strings.Split(path, ".")[0]] {
+			return fmt.Errorf("%w: field %s can't be updated", ErrInvalidFieldMask, path)
+		}
+		copyField(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(path, "."))
+	}
+	return nil
+}
+
+func copyField(dst protoreflect.Message, src protoreflect.Message, path []string) {
+	field := src.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if len(path) > 1 {
+		copyField(dst.Mutable(field).Message(), src.Get(field).Message(), path[1:])
+		return
+	}
+	if !src.Has(field) {
+		dst.Clear(field)
+		return
+	}
+	dst.Set(field, src.Get(field))
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/serializer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	laptopLogFile       = "laptops.wal"
+	laptopSnapshotFile  = "laptops.snapshot"
+	defaultCompactEvery = 1000
+	maxLaptopRecordSize = 1 << 20
+	logHeaderSize       = 12 // length, checksum of the data and checksum of both
+)
+
+var (
+	ErrLaptopTooLarge = errors.New("laptop is too large to store")
+
+	errLogChecksum       = errors.New("record checksum mismatch")
+	errLogHeaderChecksum = errors.New("record header checksum mismatch")
+	logCrcTable          = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// FileLaptopStore is a LaptopStore that keeps its laptops across restarts.
+// Every change is appended to a write-ahead log before it is applied and acknowledged.
+// Records start with a header holding their length and the CRC32 of their data, checked by
+// its own CRC32, and the log is compacted into a snapshot every compactEvery records.
+// On startup the snapshot is loaded and the log is replayed on top of it.
+// Reads are served by an InMemoryLaptopStore.
+type FileLaptopStore struct {
+	mutex        sync.Mutex // serialises changes, so that the log has the order of the memory store
+	memory       *InMemoryLaptopStore
+	folder       string
+	logFile      *os.File
+	logSize      int64 // size of the valid records in the log
+	records      int   // records in the log since the last snapshot
+	compactEvery int
+}
+
+func NewFileLaptopStore(folder string) (*FileLaptopStore, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, fmt.Errorf("can't create data folder: %v", err)
+	}
+
+	store := &FileLaptopStore{
+		memory:       NewInMemoryLaptopStore(),
+		folder:       folder,
+		compactEvery: defaultCompactEvery,
+	}
+	if err := store.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := store.replayLog(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *FileLaptopStore) loadSnapshot() error {
+	path := filepath.Join(store.folder, laptopSnapshotFile)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	snapshot := &pb.LaptopSnapshot{}
+	if err := serializer.ReadProtobufFromBinaryFile(path, snapshot); err != nil {
+		return fmt.Errorf("can't load laptop snapshot: %v", err)
+	}
+	for _, laptop := range snapshot.GetLaptops() {
+		store.memory.restore(laptop)
+	}
+	return nil
+}
+
+// replayLog applies the records of the log to the memory store. A record cut short by a
+// crash while it was written is never acknowledged, so it is dropped from the log. So is a last
+// record whose data doesn't match its checksum, which a crash can leave when it only wrote some
+// of its pages. Any other mismatch is a corruption of acknowledged records and fails.
+func (store *FileLaptopStore) replayLog() error {
+	file, err := os.OpenFile(filepath.Join(store.folder, laptopLogFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open laptop log: %v", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		record, size, err := readLogRecord(reader)
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			log.Printf("dropping incomplete laptop log record at offset %d", store.logSize)
+			break
+		} else if err == errLogChecksum {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				log.Printf("dropping torn laptop log record at offset %d", store.logSize)
+				break
+			}
+			// records after it were acknowledged, so the log is corrupted
+			file.Close()
+			return fmt.Errorf("can't read laptop log record at offset %d: %w", store.logSize, err)
+		} else if err != nil {
+			file.Close()
+			return fmt.Errorf("can't read laptop log record at offset %d: %w", store.logSize, err)
+		}
+
+		store.apply(record)
+		store.logSize += size
+		store.records++
+	}
+
+	if err := file.Truncate(store.logSize); err != nil {
+		file.Close()
+		return fmt.Errorf("can't truncate laptop log: %v", err)
+	}
+	if _, err := file.Seek(store.logSize, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("can't seek laptop log: %v", err)
+	}
+	store.logFile = file
+
+	if store.records >= store.compactEvery {
+		return store.compact()
+	}
+	return nil
+}
+
+// readLogRecord reads a record and returns it with the number of bytes it took in the log.
+// It fails with io.ErrUnexpectedEOF only if the log ends within the record, with
+// errLogHeaderChecksum if its header is corrupted and with errLogChecksum if its data is.
+func readLogRecord(reader *bufio.Reader) (*pb.LaptopLogRecord, int64, error) {
+	var header [logHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(header[:8], logCrcTable) != binary.LittleEndian.Uint32(header[8:]) {
+		return nil, 0, errLogHeaderChecksum
+	}
+	length := binary.LittleEndian.Uint32(header[:4])
+	if length > maxLaptopRecordSize {
+		return nil, 0, fmt.Errorf("record of %d bytes is too big", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err == io.EOF {
+		return nil, 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(data, logCrcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, 0, errLogChecksum
+	}
+
+	record := &pb.LaptopLogRecord{}
+	if err := proto.Unmarshal(data, record); err != nil {
+		return nil, 0, err
+	}
+	return record, logHeaderSize + int64(length), nil
+}
+
+func (store *FileLaptopStore) apply(record *pb.LaptopLogRecord) {
+	switch change := record.GetChange().(type) {
+	case *pb.LaptopLogRecord_Put:
+		store.memory.restore(change.Put)
+	case *pb.LaptopLogRecord_DeleteId:
+		store.memory.discard(change.DeleteId)
+	}
+}
+
+// appendRecord writes the record to the end of the log and syncs it to disk. It fails with
+// ErrLaptopTooLarge, writing nothing, if the record couldn't be read back. The caller must hold the mutex.
+func (store *FileLaptopStore) appendRecord(record *pb.LaptopLogRecord) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("can't marshal laptop log record: %v", err)
+	}
+	if len(data) > maxLaptopRecordSize {
+		return fmt.Errorf("%w: record of %d bytes is bigger than %d", ErrLaptopTooLarge, len(data), maxLaptopRecordSize)
+	}
+	header := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(data, logCrcTable))
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, logCrcTable))
+	data = append(header, data...)
+
+	_, err = store.logFile.Write(data)
+	if err == nil {
+		err = store.logFile.Sync()
+	}
+	if err != nil {
+		// drop whatever part of the record was written, so that the next one follows valid records
+		store.logFile.Truncate(store.logSize)
+		store.logFile.Seek(store.logSize, io.SeekStart)
+		return fmt.Errorf("can't write laptop log record: %v", err)
+	}
+	store.logSize += int64(len(data))
+	store.records++
+	return nil
+}
+
+// compactIfDue compacts the log once it has compactEvery records, after their changes
+// are applied to the memory store. The caller must hold the mutex.
+func (store *FileLaptopStore) compactIfDue() {
+	if store.records >= store.compactEvery {
+		if err := store.compact(); err != nil {
+			// the records are durable in the log, compaction will be tried again on the next change
+			log.Printf("can't compact laptop log: %v", err)
+		}
+	}
+}
+
+// compact writes every laptop to a new snapshot and empties the log.
+// The caller must hold the mutex.
+func (store *FileLaptopStore) compact() error {
+	path := filepath.Join(store.folder, laptopSnapshotFile)
+	tmpPath := path + ".tmp"
+
+	snapshot := &pb.LaptopSnapshot{Laptops: store.memory.snapshot()}
+	if err := serializer.WriteProtobufToBinaryFile(snapshot, tmpPath); err != nil {
+		return err
+	}
+	if err := syncFile(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("can't replace laptop snapshot: %v", err)
+	}
+	if err := syncFile(store.folder); err != nil {
+		return err
+	}
+
+	// replaying the log on top of the snapshot gives the same laptops,
+	// so a crash before the truncation is harmless
+	if err := store.logFile.Truncate(0); err != nil {
+		return fmt.Errorf("can't truncate laptop log: %v", err)
+	}
+	if _, err := store.logFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("can't seek laptop log: %v", err)
+	}
+	store.logSize = 0
+	store.records = 0
+	return nil
+}
+
+func syncFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open file to sync: %v", err)
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("can't sync file: %v", err)
+	}
+	return nil
+}
+
+// Close compacts the log and closes it
+func (store *FileLaptopStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	err := store.compact()
+	if closeErr := store.logFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Save, Update and Delete check the change against the memory store, make it durable in the log
+// and only then apply it to the memory store, so that readers never see a change that may be lost
+func (store *FileLaptopStore) Save(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.memory.mutex.RLock()
+	saved, err := store.memory.toSave(laptop)
+	store.memory.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	err = store.appendRecord(&pb.LaptopLogRecord{
+		Change: &pb.LaptopLogRecord_Put{Put: saved},
+	})
+	if err != nil {
+		return err
+	}
+	store.memory.restore(saved)
+	store.compactIfDue()
+	return nil
+}
+
+func (store *FileLaptopStore) FindById(id string) (*pb.Laptop, error) {
+	return store.memory.FindById(id)
+}
+
+func (store *FileLaptopStore) Update(laptop *pb.Laptop,
+	mask *fieldmaskpb.FieldMask,
+	expectedVersion uint64,
+) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.memory.mutex.RLock()
+	updated, err := store.memory.toUpdate(laptop, mask, expectedVersion)
+	store.memory.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	err = store.appendRecord(&pb.LaptopLogRecord{
+		Change: &pb.LaptopLogRecord_Put{Put: updated},
+	})
+	if err != nil {
+		return nil, err
+	}
+	store.memory.restore(updated)
+	store.compactIfDue()
+	return createDeepCopy(updated)
+}
+
+func (store *FileLaptopStore) Delete(id string, expectedVersion uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.memory.mutex.RLock()
+	err := store.memory.checkDelete(id, expectedVersion)
+	store.memory.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	err = store.appendRecord(&pb.LaptopLogRecord{
+		Change: &pb.LaptopLogRecord_DeleteId{DeleteId: id},
+	})
+	if err != nil {
+		return err
+	}
+	store.memory.discard(id)
+	store.compactIfDue()
+	return nil
+}
+
+func (store *FileLaptopStore) List(afterId string, limit int) ([]*pb.Laptop, error) {
+	return store.memory.List(afterId, limit)
+}
+
+func (store *FileLaptopStore) Search(filter *pb.Filter,
+	options SearchOptions,
+	ctx context.Context,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.memory.Search(filter, options, ctx, found)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func requireStoredLaptops(t *testing.T, store LaptopStore, expected ...*pb.Laptop) {
+	laptops, err := store.List("", len(expected)+1)
+	require.NoError(t, err)
+	require.Len(t, laptops, len(expected))
+	for _, laptop := range expected {
+		stored, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		requireSameLaptop(t, laptop, stored)
+	}
+}
+
+func TestFileLaptopStoreReplay(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	laptop3 := sample.NewLaptop()
+	for _, laptop := range []*pb.Laptop{laptop1, laptop2, laptop3} {
+		require.NoError(t, store.Save(laptop))
+	}
+	require.ErrorIs(t, store.Save(laptop1), ErrAlreadyExists)
+
+	laptop1.PriceUsd = 999
+	updated, err := store.Update(laptop1, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 1)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(laptop2.Id, 0))
+	saved3, err := store.FindById(laptop3.Id)
+	require.NoError(t, err)
+
+	// reopen without closing, as after a crash
+	reopened, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+	requireStoredLaptops(t, reopened, updated, saved3)
+}
+
+func TestFileLaptopStoreCompaction(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+	store.compactEvery = 3
+
+	var saved []*pb.Laptop
+	for i := 0; i < 4; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		stored, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		saved = append(saved, stored)
+	}
+	require.FileExists(t, filepath.Join(folder, laptopSnapshotFile))
+	require.Equal(t, 1, store.records)
+
+	reopened, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+	requireStoredLaptops(t, reopened, saved...)
+
+	require.NoError(t, reopened.Close())
+	info, err := os.Stat(filepath.Join(folder, laptopLogFile))
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	reopened, err = NewFileLaptopStore(folder)
+	require.NoError(t, err)
+	requireStoredLaptops(t, reopened, saved...)
+}
+
+func TestFileLaptopStoreTruncatedLog(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+
+	var saved []*pb.Laptop
+	var sizes []int64
+	for i := 0; i < 3; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		stored, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		saved = append(saved, stored)
+		sizes = append(sizes, store.logSize)
+	}
+	logPath := filepath.Join(folder, laptopLogFile)
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+
+	// cut the last record at its length prefix, in its middle and just before its end
+	for _, size := range []int64{sizes[1] + 1, (sizes[1] + sizes[2]) / 2, sizes[2] - 1} {
+		crashFolder := t.TempDir()
+		crashLogPath := filepath.Join(crashFolder, laptopLogFile)
+		require.NoError(t, os.WriteFile(crashLogPath, data[:size], 0644))
+
+		recovered, err := NewFileLaptopStore(crashFolder)
+		require.NoError(t, err)
+		requireStoredLaptops(t, recovered, saved[:2]...)
+
+		info, err := os.Stat(crashLogPath)
+		require.NoError(t, err)
+		require.Equal(t, sizes[1], info.Size())
+
+		// new records must follow the last complete one
+		laptop := sample.NewLaptop()
+		require.NoError(t, recovered.Save(laptop))
+		stored, err := recovered.FindById(laptop.Id)
+		require.NoError(t, err)
+
+		recovered, err = NewFileLaptopStore(crashFolder)
+		require.NoError(t, err)
+		requireStoredLaptops(t, recovered, saved[0], saved[1], stored)
+	}
+}
+
+func TestFileLaptopStoreCorruptedLog(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+
+	var saved []*pb.Laptop
+	var sizes []int64
+	for i := 0; i < 3; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		stored, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		saved = append(saved, stored)
+		sizes = append(sizes, store.logSize)
+	}
+	data, err := os.ReadFile(filepath.Join(folder, laptopLogFile))
+	require.NoError(t, err)
+
+	corrupt := func(offset int64) string {
+		crashFolder := t.TempDir()
+		corrupted := append([]byte{}, data...)
+		corrupted[offset] ^= 0xff
+		require.NoError(t, os.WriteFile(filepath.Join(crashFolder, laptopLogFile), corrupted, 0644))
+		return crashFolder
+	}
+
+	// a last record with pages that weren't written is dropped like a truncated one
+	crashFolder := corrupt(sizes[2] - 1)
+	recovered, err := NewFileLaptopStore(crashFolder)
+	require.NoError(t, err)
+	requireStoredLaptops(t, recovered, saved[:2]...)
+	info, err := os.Stat(filepath.Join(crashFolder, laptopLogFile))
+	require.NoError(t, err)
+	require.Equal(t, sizes[1], info.Size())
+
+	// records after a corrupted one were acknowledged, so they can't be dropped
+	_, err = NewFileLaptopStore(corrupt(sizes[1] - 1))
+	require.ErrorIs(t, err, errLogChecksum)
+
+	// a corrupted length can't be told from a torn record, whichever record it is in
+	_, err = NewFileLaptopStore(corrupt(sizes[0]))
+	require.ErrorIs(t, err, errLogHeaderChecksum)
+	_, err = NewFileLaptopStore(corrupt(sizes[1]))
+	require.ErrorIs(t, err, errLogHeaderChecksum)
+}
+
+func TestFileLaptopStoreRecordSize(t *testing.T) {
+	t.Parallel()
+
+	folder := t.TempDir()
+	store, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+
+	// the name is grown until the record of the saved laptop is as big as can be read back
+	recordSize := func(laptop *pb.Laptop) int {
+		saved := proto.Clone(laptop).(*pb.Laptop)
+		saved.Version = 1
+		return proto.Size(&pb.LaptopLogRecord{Change: &pb.LaptopLogRecord_Put{Put: saved}})
+	}
+	largest := sample.NewLaptop()
+	for size := recordSize(largest); size != maxLaptopRecordSize; size = recordSize(largest) {
+		// the lengths prefixing the name and the laptop grow with them
+		largest.Name = strings.Repeat("x", len(largest.Name)+maxLaptopRecordSize-size)
+	}
+	tooLarge := proto.Clone(largest).(*pb.Laptop)
+	tooLarge.Id = sample.NewLaptop().Id
+	tooLarge.Name += "x"
+
+	require.NoError(t, store.Save(largest))
+	logSize := store.logSize
+	require.ErrorIs(t, store.Save(tooLarge), ErrLaptopTooLarge)
+	require.Equal(t, logSize, store.logSize)
+	_, err = store.FindById(tooLarge.Id)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	saved, err := store.FindById(largest.Id)
+	require.NoError(t, err)
+	reopened, err := NewFileLaptopStore(folder)
+	require.NoError(t, err)
+	requireStoredLaptops(t, reopened, saved)
+}
+
+func TestFileLaptopStoreFailedWrite(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewFileLaptopStore(t.TempDir())
+	require.NoError(t, err)
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+	saved, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+
+	// changes that aren't durable are never seen
+	require.NoError(t, store.logFile.Close())
+	require.Error(t, store.Save(sample.NewLaptop()))
+	laptop.PriceUsd = 1
+	_, err = store.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 0)
+	require.Error(t, err)
+	require.Error(t, store.Delete(laptop.Id, 0))
+	requireStoredLaptops(t, store, saved)
+}
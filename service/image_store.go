@@ -1,64 +1,208 @@
 package service
 
 import (
-	"bytes"
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
 	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/validation"
 )
 
 type ImageStore interface {
-	Save(laptopId string, imageType pb.ImageType, imageData bytes.Buffer) (string, error)
+	// Save stores the image read from imageData as the last one of the laptop, and as its primary
+	// image if it is the first one. Nothing is stored if reading fails, or if the data is not an
+	// image of the type, in which case the error is a *validation.Error.
+	Save(laptopId string, imageType pb.ImageType, imageData io.Reader) (string, error)
+	// SetPrimary makes the image the primary one of its laptop
+	SetPrimary(imageId string) error
+	// List returns the images of the laptop in upload order
+	List(laptopId string) ([]*ImageInfo, error)
+	// Open returns the image and a reader of its data, which the caller must close.
+	// It fails with ErrNotFound if no image has the id.
+	Open(imageId string) (*ImageInfo, io.ReadCloser, error)
+	DeleteByLaptopId(laptopId string) error
 }
 
 type DiskImageStore struct {
 	mutex       sync.RWMutex
 	imageFolder string
 	images      map[string]*ImageInfo
+	galleries   map[string][]string // laptop id -> image ids in upload order
 }
 
 type ImageInfo struct {
+	Id       string
 	LaptopId string
 	Type     *pb.ImageType
 	Path     string
+	Size     int
+	Primary  bool
+	Width    int
+	Height   int
 }
 
-func NewDiskImageStore(imageFolder string) *DiskImageStore {
+// NewDiskImageStore returns a store keeping images in the folder, after removing the temporary
+// files of images that a previous run was still writing when it stopped
+func NewDiskImageStore(imageFolder string) (*DiskImageStore, error) {
+	paths, err := filepath.Glob(filepath.Join(imageFolder, "*.tmp"))
+	if err != nil {
+		return nil, fmt.Errorf("error while listing temporary files: %v", err)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error while deleting temporary file: %v", err)
+		}
+	}
+
 	return &DiskImageStore{
 		imageFolder: imageFolder,
 		images:      make(map[string]*ImageInfo),
-	}
+		galleries:   make(map[string][]string),
+	}, nil
 }
 
 func (imageStore *DiskImageStore) Save(laptopId string,
-	imageType pb.ImageType, imageData bytes.Buffer) (string, error) {
+	imageType pb.ImageType, imageData io.Reader) (string, error) {
 	imageId, err := uuid.NewRandom()
 	if err != nil {
 		return "", fmt.Errorf("error while creating imageId: %v", err)
 	}
-	imagePath := fmt.Sprintf("%s/%s.%s", imageStore.imageFolder, laptopId, imageType)
+	// every image has a file of its own, so that uploads never overwrite each other
+	imagePath := filepath.Join(imageStore.imageFolder, imageId.String()+"."+strings.ToLower(imageType.String()))
 
-	file, err := os.Create(imagePath)
+	var width, height int
+	size, err := writeFileAtomically(imagePath, imageData, func(file *os.File) (err error) {
+		width, height, err = validation.ValidateImage(imageType, bufio.NewReader(file))
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("error while creating file: %v", err)
-	}
-	defer file.Close()
-	_, err = imageData.WriteTo(file)
-	if err != nil {
-		return "", fmt.Errorf("error while writing to file: %v", err)
+		return "", err
 	}
 
 	imageStore.mutex.Lock()
 	defer imageStore.mutex.Unlock()
 
+	gallery := imageStore.galleries[laptopId]
 	imageStore.images[imageId.String()] = &ImageInfo{
+		Id:       imageId.String(),
 		LaptopId: laptopId,
 		Type:     &imageType,
 		Path:     imagePath,
+		Size:     int(size),
+		Primary:  len(gallery) == 0,
+		Width:    width,
+		Height:   height,
 	}
+	imageStore.galleries[laptopId] = append(gallery, imageId.String())
 
 	return imageId.String(), nil
 }
+
+// writeFileAtomically copies data to a temporary file next to path and renames it to path
+// once everything is written and check accepts the file, read from its start, so that a failed
+// or cancelled upload never leaves a partial image
+func writeFileAtomically(path string, data io.Reader, check func(file *os.File) error) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("error while creating file: %v", err)
+	}
+	tempPath := file.Name()
+
+	size, err := io.Copy(file, data)
+	if err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("error while writing to file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("error while reading file: %v", err)
+	}
+	if err := check(file); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("error while writing to file: %v", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("error while renaming file: %v", err)
+	}
+	return size, nil
+}
+
+func (imageStore *DiskImageStore) SetPrimary(imageId string) error {
+	imageStore.mutex.Lock()
+	defer imageStore.mutex.Unlock()
+
+	info, ok := imageStore.images[imageId]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, id := range imageStore.galleries[info.LaptopId] {
+		imageStore.images[id].Primary = id == imageId
+	}
+	return nil
+}
+
+func (imageStore *DiskImageStore) List(laptopId string) ([]*ImageInfo, error) {
+	imageStore.mutex.RLock()
+	defer imageStore.mutex.RUnlock()
+
+	gallery := imageStore.galleries[laptopId]
+	images := make([]*ImageInfo, len(gallery))
+	for i, id := range gallery {
+		other := *imageStore.images[id]
+		images[i] = &other
+	}
+	return images, nil
+}
+
+func (imageStore *DiskImageStore) Open(imageId string) (*ImageInfo, io.ReadCloser, error) {
+	imageStore.mutex.RLock()
+	info, ok := imageStore.images[imageId]
+	var other ImageInfo
+	if ok {
+		other = *info
+	}
+	imageStore.mutex.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	file, err := os.Open(other.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while opening file: %w", err)
+	}
+	return &other, file, nil
+}
+
+// DeleteByLaptopId removes every image stored for the laptop from disk.
+func (imageStore *DiskImageStore) DeleteByLaptopId(laptopId string) error {
+	imageStore.mutex.Lock()
+	defer imageStore.mutex.Unlock()
+
+	gallery := imageStore.galleries[laptopId]
+	for i, imageId := range gallery {
+		err := os.Remove(imageStore.images[imageId].Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// keep the images that are left, so that deleting can be retried
+			imageStore.galleries[laptopId] = gallery[i:]
+			return fmt.Errorf("error while deleting file: %v", err)
+		}
+		delete(imageStore.images, imageId)
+	}
+	delete(imageStore.galleries, laptopId)
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/pokala15/pcbook/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestImageStore(t *testing.T, imageFolder string) *DiskImageStore {
+	imageStore, err := NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+	return imageStore
+}
+
+func TestDiskImageStoreGallery(t *testing.T) {
+	t.Parallel()
+
+	imageStore := newTestImageStore(t, t.TempDir())
+	laptopId := "laptop"
+
+	var ids []string
+	for width := 1; width <= 3; width++ {
+		imageId, err := imageStore.Save(laptopId, pb.ImageType_JPG, bytes.NewReader(sample.NewImage(pb.ImageType_JPG, width, 2)))
+		require.NoError(t, err)
+		require.Equal(t, width, imageStore.images[imageId].Width)
+		require.Equal(t, 2, imageStore.images[imageId].Height)
+		ids = append(ids, imageId)
+	}
+	otherId, err := imageStore.Save("other", pb.ImageType_PNG, bytes.NewReader(sample.NewImage(pb.ImageType_PNG, 2, 2)))
+	require.NoError(t, err)
+
+	requirePrimary := func(primaryId string) {
+		images, err := imageStore.List(laptopId)
+		require.NoError(t, err)
+		require.Len(t, images, len(ids))
+		for i, image := range images {
+			require.Equal(t, ids[i], image.Id)
+			require.Equal(t, image.Id == primaryId, image.Primary)
+		}
+	}
+	requirePrimary(ids[0])
+
+	require.NoError(t, imageStore.SetPrimary(ids[2]))
+	requirePrimary(ids[2])
+	require.ErrorIs(t, imageStore.SetPrimary("unknown"), ErrNotFound)
+
+	require.NoError(t, imageStore.DeleteByLaptopId(laptopId))
+	images, err := imageStore.List(laptopId)
+	require.NoError(t, err)
+	require.Empty(t, images)
+
+	images, err = imageStore.List("other")
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	require.Equal(t, otherId, images[0].Id)
+	require.True(t, images[0].Primary)
+	require.FileExists(t, images[0].Path)
+}
+
+func TestDiskImageStoreSaveFailure(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	imageStore := newTestImageStore(t, imageFolder)
+
+	readErr := errors.New("upload cancelled")
+	imageData := io.MultiReader(strings.NewReader("half an image"), iotest.ErrReader(readErr))
+	_, err := imageStore.Save("laptop", pb.ImageType_JPG, imageData)
+	require.ErrorIs(t, err, readErr)
+
+	images, err := imageStore.List("laptop")
+	require.NoError(t, err)
+	require.Empty(t, images)
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestDiskImageStoreRemovesTemporaryFiles(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	// files of images a previous run was writing are removed on startup
+	stale := filepath.Join(imageFolder, "image.jpg.123.tmp")
+	require.NoError(t, os.WriteFile(stale, []byte("half an image"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(imageFolder, "image.jpg"), []byte("image"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(imageFolder, "stale.upload"), []byte("upload"), 0o644))
+	newTestImageStore(t, imageFolder)
+
+	require.NoFileExists(t, stale)
+	require.FileExists(t, filepath.Join(imageFolder, "image.jpg"))
+	require.FileExists(t, filepath.Join(imageFolder, "stale.upload"))
+}
+
+func TestDiskImageStoreSaveInvalid(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	imageStore := newTestImageStore(t, imageFolder)
+
+	_, err := imageStore.Save("laptop", pb.ImageType_JPG, bytes.NewReader(sample.NewImage(pb.ImageType_GIF, 2, 2)))
+	var validationErr *validation.Error
+	require.ErrorAs(t, err, &validationErr)
+
+	require.Empty(t, imageStore.images)
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
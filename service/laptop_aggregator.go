@@ -0,0 +1,127 @@
+package service
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+// bucket is a half-open range [from, to) of values, to == 0 meaning unbounded
+type bucket struct {
+	label string
+	from  float64
+	to    float64
+}
+
+var ramBuckets = []bucket{
+	{label: "<8GB", from: 0, to: 8},
+	{label: "8-16GB", from: 8, to: 16},
+	{label: "16-32GB", from: 16, to: 32},
+	{label: "32-64GB", from: 32, to: 64},
+	{label: ">=64GB", from: 64},
+}
+
+var priceBuckets = []bucket{
+	{label: "<1000", from: 0, to: 1000},
+	{label: "1000-2000", from: 1000, to: 2000},
+	{label: "2000-3000", from: 2000, to: 3000},
+	{label: ">=3000", from: 3000},
+}
+
+// laptopAggregator computes facet counts and price statistics of the laptops added to it
+type laptopAggregator struct {
+	total        uint32
+	brands       map[string]uint32
+	cpuBrands    map[string]uint32
+	panels       map[string]uint32
+	ramBuckets   []uint32
+	priceBuckets []uint32
+	minPrice     float64
+	maxPrice     float64
+	sumPrice     float64
+}
+
+func newLaptopAggregator() *laptopAggregator {
+	return &laptopAggregator{
+		brands:       make(map[string]uint32),
+		cpuBrands:    make(map[string]uint32),
+		panels:       make(map[string]uint32),
+		ramBuckets:   make([]uint32, len(ramBuckets)),
+		priceBuckets: make([]uint32, len(priceBuckets)),
+	}
+}
+
+func (aggregator *laptopAggregator) add(laptop *pb.Laptop) {
+	aggregator.brands[laptop.GetBrand()]++
+	aggregator.cpuBrands[laptop.GetCpu().GetBrand()]++
+	aggregator.panels[laptop.GetScreen().GetPanel().String()]++
+
+	gigabyteBits, _ := units.Default.UnitBits(pb.Memory_GIGABYTE)
+	ramGb := float64(units.SaturatedBits(laptop.GetRam())) / float64(gigabyteBits)
+	aggregator.ramBuckets[findBucket(ramBuckets, ramGb)]++
+
+	price := laptop.GetPriceUsd()
+	aggregator.priceBuckets[findBucket(priceBuckets, price)]++
+	if aggregator.total == 0 || price < aggregator.minPrice {
+		aggregator.minPrice = price
+	}
+	if aggregator.total == 0 || price > aggregator.maxPrice {
+		aggregator.maxPrice = price
+	}
+	aggregator.sumPrice += price
+	aggregator.total++
+}
+
+func (aggregator *laptopAggregator) response() *pb.AggregateLaptopsResponse {
+	response := &pb.AggregateLaptopsResponse{
+		Total:        aggregator.total,
+		Brands:       facetCounts(aggregator.brands),
+		CpuBrands:    facetCounts(aggregator.cpuBrands),
+		Panels:       facetCounts(aggregator.panels),
+		RamBuckets:   bucketCounts(ramBuckets, aggregator.ramBuckets),
+		PriceBuckets: bucketCounts(priceBuckets, aggregator.priceBuckets),
+	}
+	if aggregator.total > 0 {
+		response.Price = &pb.PriceStats{
+			MinUsd: aggregator.minPrice,
+			MaxUsd: aggregator.maxPrice,
+			AvgUsd: aggregator.sumPrice / float64(aggregator.total),
+		}
+	}
+	return response
+}
+
+func findBucket(buckets []bucket, value float64) int {
+	for i, b := range buckets {
+		if value >= b.from && (b.to == 0 || value < b.to) {
+			return i
+		}
+	}
+	return 0
+}
+
+// facetCounts returns the counts from the most to the least frequent value
+func facetCounts(counts map[string]uint32) []*pb.FacetCount {
+	facets := make([]*pb.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, &pb.FacetCount{Value: value, Count: count})
+	}
+	slices.SortFunc(facets, func(a *pb.FacetCount, b *pb.FacetCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+	return facets
+}
+
+// bucketCounts returns the counts of every bucket in range order, including empty ones
+func bucketCounts(buckets []bucket, counts []uint32) []*pb.FacetCount {
+	facets := make([]*pb.FacetCount, len(buckets))
+	for i, b := range buckets {
+		facets[i] = &pb.FacetCount{Value: b.label, Count: counts[i]}
+	}
+	return facets
+}
@@ -1,16 +1,29 @@
 package service
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	goimage "image"
 	"io"
 	"net"
+	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/query"
 	"github.com/pokala15/pcbook/sample"
 	"github.com/pokala15/pcbook/serializer"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClientCreateLaptop(t *testing.T) {
@@ -33,6 +46,7 @@ func TestClientCreateLaptop(t *testing.T) {
 	// check for the object saved
 	savedLaptop, err := laptopStore.FindById(response.Id)
 	require.NoError(t, err)
+	laptop.Version = 1
 	requireSameLaptop(t, laptop, savedLaptop)
 }
 
@@ -94,8 +108,777 @@ func TestClientSearchLaptop(t *testing.T) {
 	require.Equal(t, len(expectedIds), found)
 }
 
+func TestClientSearchLaptopSorted(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < 6; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+	_, serverAdd := startTestLaptopServer(t, store, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Filter: &pb.Filter{
+			MaxPriceUsd: 5000,
+			MinRam:      &pb.Memory{Unit: pb.Memory_GIGABYTE, Value: 1},
+		},
+		SortBy: &pb.Sort{
+			Field:     pb.Sort_PRICE,
+			Direction: pb.Sort_DESCENDING,
+		},
+		MaxResults: 4,
+	})
+	require.NoError(t, err)
+
+	var prices []float64
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		prices = append(prices, response.GetLaptop().GetPriceUsd())
+	}
+	require.Len(t, prices, 4)
+	require.True(t, slices.IsSortedFunc(prices, func(a float64, b float64) int {
+		return cmp.Compare(b, a)
+	}))
+}
+
+func TestClientSearchLaptopByQuery(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	expectedIds := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		laptop := sample.NewLaptop()
+		laptop.Brand = "Dell"
+		laptop.PriceUsd = 1800
+		if i%2 == 0 {
+			laptop.Brand = "Apple"
+		} else {
+			expectedIds[laptop.Id] = true
+		}
+		err := store.Save(laptop)
+		require.NoError(t, err)
+	}
+	_, serverAdd := startTestLaptopServer(t, store, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Query: "brand:dell price<2000",
+	})
+	require.NoError(t, err)
+	found := 0
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Contains(t, expectedIds, response.GetLaptop().GetId())
+		found += 1
+	}
+	require.Equal(t, len(expectedIds), found)
+
+	stream, err = laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Query: "brand:dell price<",
+	})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "position 17")
+
+	// queries that would exhaust the stack of the server are rejected
+	for _, tooBig := range []string{
+		strings.Repeat("NOT ", query.MaxDepth+1) + "price<2000",
+		strings.Repeat("(", 1e6) + "price<2000" + strings.Repeat(")", 1e6),
+	} {
+		stream, err = laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{Query: tooBig})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestClientSearchLaptopByText(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	names := []string{"Thinkpad X1", "Thinkpad P1", "Latitude"}
+	var ids []string
+	for _, name := range names {
+		laptop := sample.NewLaptop()
+		laptop.Brand = "Lenovo"
+		laptop.Name = name
+		laptop.Cpu.Brand = "intel"
+		laptop.Cpu.Name = "Core i7-9750H"
+		laptop.Gpus = []*pb.GPU{{Brand: "NVIDIA", Name: "RTX 2070"}}
+		err := store.Save(laptop)
+		require.NoError(t, err)
+		ids = append(ids, laptop.Id)
+	}
+	_, serverAdd := startTestLaptopServer(t, store, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Text: "thinkpad x",
+	})
+	require.NoError(t, err)
+	var found []string
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		found = append(found, response.GetLaptop().GetId())
+	}
+	// "x" is the prefix of x1 only
+	require.Equal(t, []string{ids[0]}, found)
+
+	stream, err = laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{
+		Text: " -- ",
+	})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestClientAggregateLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	prices := []float64{900, 1500, 2500, 3500}
+	for i, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.Brand = "Dell"
+		if i == 0 {
+			laptop.Brand = "Apple"
+		}
+		laptop.Cpu.Brand = "intel"
+		laptop.Screen.Panel = pb.Screen_IPS
+		laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+		laptop.PriceUsd = price
+		err := store.Save(laptop)
+		require.NoError(t, err)
+	}
+	_, serverAdd := startTestLaptopServer(t, store, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	response, err := laptopClient.AggregateLaptops(context.Background(), &pb.AggregateLaptopsRequest{
+		Filter: &pb.Filter{
+			MaxPriceUsd: 3000,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), response.GetTotal())
+	requireFacetCounts(t, map[string]uint32{"Dell": 2, "Apple": 1}, response.GetBrands())
+	requireFacetCounts(t, map[string]uint32{"intel": 3}, response.GetCpuBrands())
+	requireFacetCounts(t, map[string]uint32{"IPS": 3}, response.GetPanels())
+	requireFacetCounts(t, map[string]uint32{"<8GB": 0, "8-16GB": 0, "16-32GB": 3, "32-64GB": 0, ">=64GB": 0},
+		response.GetRamBuckets())
+	requireFacetCounts(t, map[string]uint32{"<1000": 1, "1000-2000": 1, "2000-3000": 1, ">=3000": 0},
+		response.GetPriceBuckets())
+	require.Equal(t, 900.0, response.GetPrice().GetMinUsd())
+	require.Equal(t, 2500.0, response.GetPrice().GetMaxUsd())
+	require.InDelta(t, 1633.33, response.GetPrice().GetAvgUsd(), 0.01)
+}
+
+func TestClientGetLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	response, err := laptopClient.GetLaptop(context.Background(), &pb.GetLaptopRequest{
+		Id: laptop.Id,
+	})
+	require.NoError(t, err)
+	laptop.Version = 1
+	requireSameLaptop(t, laptop, response.GetLaptop())
+
+	_, err = laptopClient.GetLaptop(context.Background(), &pb.GetLaptopRequest{
+		Id: sample.NewLaptop().Id,
+	})
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+}
+
+func TestClientDeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := newTestImageStore(t, t.TempDir())
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	var imagePaths []string
+	for _, imageType := range []pb.ImageType{pb.ImageType_JPG, pb.ImageType_PNG} {
+		imageId, err := imageStore.Save(laptop.Id, imageType, bytes.NewReader(sample.NewImage(imageType, 4, 3)))
+		require.NoError(t, err)
+		imagePaths = append(imagePaths, imageStore.images[imageId].Path)
+	}
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{
+		Id: laptop.Id,
+	})
+	require.NoError(t, err)
+
+	_, err = laptopStore.FindById(laptop.Id)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Empty(t, imageStore.images)
+	for _, imagePath := range imagePaths {
+		require.NoFileExists(t, imagePath)
+	}
+
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{
+		Id: laptop.Id,
+	})
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+}
+
+func TestClientUploadImageUnknownLaptop(t *testing.T) {
+	t.Parallel()
+
+	imageStore := newTestImageStore(t, t.TempDir())
+	_, serverAdd := startTestLaptopServer(t, NewInMemoryLaptopStore(), imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	err = stream.Send(&pb.UploadImageRequest{
+		Info: &pb.ImageInfo{LaptopId: sample.NewLaptop().Id, ImageType: pb.ImageType_JPG},
+	})
+	require.NoError(t, err)
+
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Empty(t, imageStore.images)
+}
+
+func TestClientUploadImageTooBig(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageFolder := t.TempDir()
+	imageStore := newTestImageStore(t, imageFolder)
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	err = stream.Send(&pb.UploadImageRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: pb.ImageType_JPG},
+	})
+	require.NoError(t, err)
+	chunk := make([]byte, 64<<10)
+	for size := 0; size <= maxImageSize; size += len(chunk) {
+		if err := stream.Send(&pb.UploadImageRequest{ChunkData: chunk}); err != nil {
+			// the server gave up on the upload
+			break
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.Unknown, status.Code(err))
+	require.Empty(t, imageStore.images)
+	// the partial image is removed from the disk
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestClientUploadImageInvalid(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageFolder := t.TempDir()
+	imageStore := newTestImageStore(t, imageFolder)
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	testCases := []struct {
+		name      string
+		imageType pb.ImageType
+		data      []byte
+		field     string
+	}{
+		{
+			name:      "unknown_type",
+			imageType: pb.ImageType_UNKNOWN,
+			data:      sample.NewImage(pb.ImageType_JPG, 4, 3),
+			field:     "info.image_type",
+		},
+		{
+			name:      "mismatched_type",
+			imageType: pb.ImageType_JPG,
+			data:      sample.NewImage(pb.ImageType_PNG, 4, 3),
+			field:     "image.image_type",
+		},
+		{
+			name:      "not_an_image",
+			imageType: pb.ImageType_GIF,
+			data:      []byte("#!/bin/sh"),
+			field:     "image",
+		},
+		{
+			name:      "truncated_header",
+			imageType: pb.ImageType_PNG,
+			data:      sample.NewImage(pb.ImageType_PNG, 4, 3)[:20],
+			field:     "image",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, err := laptopClient.UploadImage(context.Background())
+			require.NoError(t, err)
+			err = stream.Send(&pb.UploadImageRequest{
+				Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: tc.imageType},
+			})
+			require.NoError(t, err)
+			// the server may have rejected the info already
+			stream.Send(&pb.UploadImageRequest{ChunkData: tc.data})
+
+			_, err = stream.CloseAndRecv()
+			st := status.Convert(err)
+			require.Equal(t, codes.InvalidArgument, st.Code())
+			require.Len(t, st.Details(), 1)
+			badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+			require.True(t, ok)
+			require.Len(t, badRequest.GetFieldViolations(), 1)
+			require.Equal(t, tc.field, badRequest.GetFieldViolations()[0].GetField())
+		})
+	}
+
+	t.Cleanup(func() {
+		require.Empty(t, imageStore.images)
+		entries, err := os.ReadDir(imageFolder)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestClientUploadAndListImages(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageStore := newTestImageStore(t, t.TempDir())
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	uploads := []struct {
+		imageType pb.ImageType
+		data      []byte
+		primary   bool
+	}{
+		{pb.ImageType_JPG, sample.NewImage(pb.ImageType_JPG, 16, 9), false},
+		{pb.ImageType_JPG, sample.NewImage(pb.ImageType_JPG, 16, 10), false},
+		{pb.ImageType_GIF, sample.NewImage(pb.ImageType_GIF, 4, 3), true},
+	}
+	var ids []string
+	for _, upload := range uploads {
+		stream, err := laptopClient.UploadImage(context.Background())
+		require.NoError(t, err)
+		err = stream.Send(&pb.UploadImageRequest{
+			Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: upload.imageType, Primary: upload.primary},
+		})
+		require.NoError(t, err)
+		err = stream.Send(&pb.UploadImageRequest{ChunkData: upload.data})
+		require.NoError(t, err)
+		response, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		ids = append(ids, response.GetImageId())
+	}
+
+	response, err := laptopClient.ListImages(context.Background(), &pb.ListImagesRequest{LaptopId: laptop.Id})
+	require.NoError(t, err)
+	require.Len(t, response.GetImages(), len(uploads))
+	for i, image := range response.GetImages() {
+		require.Equal(t, ids[i], image.GetId())
+		require.Equal(t, uploads[i].imageType, image.GetImageType())
+		require.Equal(t, uint32(len(uploads[i].data)), image.GetSize())
+		require.Equal(t, uploads[i].primary, image.GetPrimary())
+		config, _, err := goimage.DecodeConfig(bytes.NewReader(uploads[i].data))
+		require.NoError(t, err)
+		require.EqualValues(t, config.Width, image.GetWidth())
+		require.EqualValues(t, config.Height, image.GetHeight())
+
+		// images of the same type are kept apart
+		data, err := os.ReadFile(imageStore.images[image.GetId()].Path)
+		require.NoError(t, err)
+		require.Equal(t, uploads[i].data, data)
+	}
+
+	_, err = laptopClient.ListImages(context.Background(), &pb.ListImagesRequest{LaptopId: sample.NewLaptop().Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientDownloadImage(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageStore := newTestImageStore(t, t.TempDir())
+
+	// larger than a chunk, so that the image is sent in several messages
+	imageData := sample.NewImage(pb.ImageType_PNG, 128, 128)
+	require.Greater(t, len(imageData), imageChunkSize)
+	imageId, err := imageStore.Save(laptop.Id, pb.ImageType_PNG, bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	stream, err := laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{ImageId: imageId})
+	require.NoError(t, err)
+
+	response, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, laptop.Id, response.GetInfo().GetLaptopId())
+	require.Equal(t, pb.ImageType_PNG, response.GetInfo().GetImageType())
+	require.True(t, response.GetInfo().GetPrimary())
+	require.Equal(t, uint32(len(imageData)), response.GetSize())
+
+	var received []byte
+	chunks := 0
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		received = append(received, response.GetChunkData()...)
+		chunks++
+	}
+	require.Equal(t, imageData, received)
+	require.Equal(t, (len(imageData)+imageChunkSize-1)/imageChunkSize, chunks)
+
+	stream, err = laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{ImageId: "unknown"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// as when the laptop is deleted between the lookup of the image and the opening of its file
+	err = os.Remove(imageStore.images[imageId].Path)
+	require.NoError(t, err)
+	stream, err = laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{ImageId: imageId})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientResumableUpload(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageStore := newTestImageStore(t, t.TempDir())
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+	ctx := context.Background()
+
+	start, err := laptopClient.StartUpload(ctx, &pb.StartUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: pb.ImageType_JPG},
+	})
+	require.NoError(t, err)
+	uploadId := start.GetUploadId()
+
+	send := func(offset int, data []byte) (*pb.UploadImageResponse, error) {
+		stream, err := laptopClient.UploadImage(ctx)
+		require.NoError(t, err)
+		err = stream.Send(&pb.UploadImageRequest{UploadId: uploadId, Offset: uint64(offset)})
+		require.NoError(t, err)
+		err = stream.Send(&pb.UploadImageRequest{ChunkData: data})
+		require.NoError(t, err)
+		return stream.CloseAndRecv()
+	}
+
+	imageData := sample.NewImage(pb.ImageType_JPG, 32, 24)
+	half := len(imageData) / 2
+	response, err := send(0, imageData[:half])
+	require.NoError(t, err)
+	require.EqualValues(t, half, response.GetSize())
+	require.Empty(t, response.GetImageId())
+
+	query, err := laptopClient.QueryUpload(ctx, &pb.QueryUploadRequest{UploadId: uploadId})
+	require.NoError(t, err)
+	require.EqualValues(t, half, query.GetReceived())
+
+	_, err = send(half+1, imageData[half:])
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+	response, err = send(half, imageData[half:])
+	require.NoError(t, err)
+	require.EqualValues(t, len(imageData), response.GetSize())
+
+	wrongChecksum := sha256.Sum256(imageData[:half])
+	_, err = laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{UploadId: uploadId, Sha256: wrongChecksum[:]})
+	require.Equal(t, codes.DataLoss, status.Code(err))
+	_, err = laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{UploadId: uploadId, Sha256: []byte("short")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Empty(t, imageStore.images)
+
+	checksum := sha256.Sum256(imageData)
+	finish, err := laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{UploadId: uploadId, Sha256: checksum[:]})
+	require.NoError(t, err)
+	require.EqualValues(t, len(imageData), finish.GetSize())
+
+	images, err := imageStore.List(laptop.Id)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	require.Equal(t, finish.GetImageId(), images[0].Id)
+	data, err := os.ReadFile(images[0].Path)
+	require.NoError(t, err)
+	require.Equal(t, imageData, data)
+
+	// retrying returns the same image, as long as the checksum matches
+	retry, err := laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{UploadId: uploadId, Sha256: checksum[:]})
+	require.NoError(t, err)
+	require.Equal(t, finish.GetImageId(), retry.GetImageId())
+	_, err = laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{UploadId: uploadId, Sha256: wrongChecksum[:]})
+	require.Equal(t, codes.DataLoss, status.Code(err))
+	_, err = send(0, imageData)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	images, err = imageStore.List(laptop.Id)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+
+	_, err = laptopClient.StartUpload(ctx, &pb.StartUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: sample.NewLaptop().Id, ImageType: pb.ImageType_JPG},
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientFinishUploadConcurrently(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+	imageStore := newTestImageStore(t, t.TempDir())
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+	ctx := context.Background()
+
+	start, err := laptopClient.StartUpload(ctx, &pb.StartUploadRequest{
+		Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: pb.ImageType_PNG},
+	})
+	require.NoError(t, err)
+	imageData := sample.NewImage(pb.ImageType_PNG, 8, 8)
+	stream, err := laptopClient.UploadImage(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UploadImageRequest{UploadId: start.GetUploadId()}))
+	require.NoError(t, stream.Send(&pb.UploadImageRequest{ChunkData: imageData}))
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	// clients whose first call timed out retry while it is still running
+	const retries = 8
+	checksum := sha256.Sum256(imageData)
+	imageIds := make(chan string, retries)
+	for i := 0; i < retries; i++ {
+		go func() {
+			response, err := laptopClient.FinishUpload(ctx, &pb.FinishUploadRequest{
+				UploadId: start.GetUploadId(),
+				Sha256:   checksum[:],
+			})
+			if err != nil {
+				imageIds <- err.Error()
+				return
+			}
+			imageIds <- response.GetImageId()
+		}()
+	}
+
+	first := <-imageIds
+	for i := 1; i < retries; i++ {
+		require.Equal(t, first, <-imageIds)
+	}
+	images, err := imageStore.List(laptop.Id)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	require.Equal(t, first, images[0].Id)
+}
+
+// deletingImageStore deletes the laptop, as DeleteLaptop does, while the image of the laptop is saved
+type deletingImageStore struct {
+	*DiskImageStore
+	laptopStore LaptopStore
+}
+
+func (imageStore *deletingImageStore) Save(laptopId string, imageType pb.ImageType, imageData io.Reader) (string, error) {
+	if err := imageStore.laptopStore.Delete(laptopId, 0); err != nil {
+		return "", err
+	}
+	if err := imageStore.DeleteByLaptopId(laptopId); err != nil {
+		return "", err
+	}
+	return imageStore.DiskImageStore.Save(laptopId, imageType, imageData)
+}
+
+func TestClientUploadImageWhileLaptopDeleted(t *testing.T) {
+	t.Parallel()
+
+	imageData := sample.NewImage(pb.ImageType_PNG, 4, 3)
+	testCases := []struct {
+		name   string
+		upload func(t *testing.T, laptopClient pb.LaptopServiceClient, info *pb.ImageInfo) error
+	}{
+		{
+			name: "upload_image",
+			upload: func(t *testing.T, laptopClient pb.LaptopServiceClient, info *pb.ImageInfo) error {
+				stream, err := laptopClient.UploadImage(context.Background())
+				require.NoError(t, err)
+				require.NoError(t, stream.Send(&pb.UploadImageRequest{Info: info}))
+				require.NoError(t, stream.Send(&pb.UploadImageRequest{ChunkData: imageData}))
+				_, err = stream.CloseAndRecv()
+				return err
+			},
+		},
+		{
+			name: "finish_upload",
+			upload: func(t *testing.T, laptopClient pb.LaptopServiceClient, info *pb.ImageInfo) error {
+				start, err := laptopClient.StartUpload(context.Background(), &pb.StartUploadRequest{Info: info})
+				require.NoError(t, err)
+				stream, err := laptopClient.UploadImage(context.Background())
+				require.NoError(t, err)
+				require.NoError(t, stream.Send(&pb.UploadImageRequest{UploadId: start.GetUploadId()}))
+				require.NoError(t, stream.Send(&pb.UploadImageRequest{ChunkData: imageData}))
+				_, err = stream.CloseAndRecv()
+				require.NoError(t, err)
+
+				checksum := sha256.Sum256(imageData)
+				_, err = laptopClient.FinishUpload(context.Background(), &pb.FinishUploadRequest{
+					UploadId: start.GetUploadId(),
+					Sha256:   checksum[:],
+				})
+				return err
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptopStore := NewInMemoryLaptopStore()
+			laptop := sample.NewLaptop()
+			err := laptopStore.Save(laptop)
+			require.NoError(t, err)
+			imageFolder := t.TempDir()
+			imageStore := &deletingImageStore{DiskImageStore: newTestImageStore(t, imageFolder), laptopStore: laptopStore}
+
+			_, serverAdd := startTestLaptopServer(t, laptopStore, imageStore)
+			laptopClient := newTestLaptopClient(t, serverAdd)
+
+			err = tc.upload(t, laptopClient, &pb.ImageInfo{LaptopId: laptop.Id, ImageType: pb.ImageType_PNG, Primary: true})
+			require.Equal(t, codes.NotFound, status.Code(err))
+			require.Empty(t, imageStore.images)
+			entries, err := os.ReadDir(imageFolder)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func TestClientListLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	for i := 0; i < 7; i++ {
+		err := laptopStore.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	_, serverAdd := startTestLaptopServer(t, laptopStore, nil)
+	laptopClient := newTestLaptopClient(t, serverAdd)
+
+	var ids []string
+	pageToken := ""
+	for {
+		response, err := laptopClient.ListLaptops(context.Background(), &pb.ListLaptopsRequest{
+			PageSize:  3,
+			PageToken: pageToken,
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(response.GetLaptops()), 3)
+		for _, laptop := range response.GetLaptops() {
+			ids = append(ids, laptop.GetId())
+		}
+
+		// laptops added while paging must not shift the following pages
+		if pageToken == "" {
+			err := laptopStore.Save(sample.NewLaptop())
+			require.NoError(t, err)
+		}
+
+		pageToken = response.GetNextPageToken()
+		if pageToken == "" {
+			break
+		}
+	}
+	require.True(t, slices.IsSorted(ids))
+	require.Equal(t, len(ids), len(slices.Compact(slices.Clone(ids))))
+	require.GreaterOrEqual(t, len(ids), 7)
+
+	// other forms of a uuid than the canonical one would point after no stored id
+	lastId := uuid.MustParse(ids[0])
+	for _, token := range []string{
+		"not a token",
+		encodePageToken("not an id"),
+		encodePageToken(strings.ToUpper(lastId.String())),
+		encodePageToken(lastId.URN()),
+		encodePageToken("{" + lastId.String() + "}"),
+		encodePageToken(strings.ReplaceAll(lastId.String(), "-", "")),
+	} {
+		_, err := laptopClient.ListLaptops(context.Background(), &pb.ListLaptopsRequest{PageToken: token})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), token)
+	}
+}
+
 func startTestLaptopServer(t *testing.T, store LaptopStore, imageStore ImageStore) (*LaptopServer, string) {
-	laptopServer := NewLaptopServer(store, imageStore)
+	laptopServer := NewLaptopServer(store, imageStore, newTestUploadStore(t, time.Hour))
 
 	grpcServer := grpc.NewServer()
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
@@ -115,6 +898,14 @@ func newTestLaptopClient(t *testing.T, serverAdd string) pb.LaptopServiceClient
 	return pb.NewLaptopServiceClient(conn)
 }
 
+func requireFacetCounts(t *testing.T, expected map[string]uint32, facets []*pb.FacetCount) {
+	counts := make(map[string]uint32)
+	for _, facet := range facets {
+		counts[facet.GetValue()] = facet.GetCount()
+	}
+	require.Equal(t, expected, counts)
+}
+
 func requireSameLaptop(t *testing.T, laptop1 *pb.Laptop, laptop2 *pb.Laptop) {
 	laptop1Json := serializer.ProtobufToJSON(laptop1)
 	laptop2Json := serializer.ProtobufToJSON(laptop2)
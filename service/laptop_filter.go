@@ -0,0 +1,144 @@
+package service
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+func isQualifiedLaptop(laptop *pb.Laptop, filter *pb.Filter) bool {
+	// searches may rely on a query alone
+	if filter == nil {
+		return true
+	}
+	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
+		return false
+	}
+	if laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
+		return false
+	}
+	if laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
+		return false
+	}
+	if !hasAtLeast(laptop.GetRam(), filter.GetMinRam()) {
+		return false
+	}
+	if !containsFold(filter.GetBrands(), laptop.GetBrand()) {
+		return false
+	}
+	if !containsFold(filter.GetNames(), laptop.GetName()) {
+		return false
+	}
+	if !hasQualifiedGpu(laptop, filter) {
+		return false
+	}
+	if !hasStorageCapacity(laptop, pb.Storage_SSD, filter.GetMinSsdCapacity()) ||
+		!hasStorageCapacity(laptop, pb.Storage_HDD, filter.GetMinHddCapacity()) {
+		return false
+	}
+	if !isQualifiedScreen(laptop.GetScreen(), filter) {
+		return false
+	}
+	if !isQualifiedKeyboard(laptop.GetKeyboard(), filter) {
+		return false
+	}
+	if filter.GetMinReleaseYear() > 0 && laptop.GetReleaseYear() < filter.GetMinReleaseYear() {
+		return false
+	}
+	if filter.GetMaxReleaseYear() > 0 && laptop.GetReleaseYear() > filter.GetMaxReleaseYear() {
+		return false
+	}
+	if maxWeight := units.MaxWeightKg(filter); maxWeight > 0 && units.WeightKg(laptop) > maxWeight {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether value is one of the values, ignoring case.
+// An empty list of values accepts everything.
+func containsFold(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
+
+func hasQualifiedGpu(laptop *pb.Laptop, filter *pb.Filter) bool {
+	if filter.GetMinGpuMemory() == nil && len(filter.GetGpuBrands()) == 0 {
+		return true
+	}
+	for _, gpu := range laptop.GetGpus() {
+		if hasAtLeast(gpu.GetMemory(), filter.GetMinGpuMemory()) &&
+			containsFold(filter.GetGpuBrands(), gpu.GetBrand()) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasStorageCapacity(laptop *pb.Laptop, driver pb.Storage_Driver, minCapacity *pb.Memory) bool {
+	if minCapacity == nil {
+		return true
+	}
+	capacity := &pb.Memory{}
+	for _, storage := range laptop.GetStorages() {
+		if storage.GetDriver() == driver {
+			var err error
+			if capacity, err = units.Add(capacity, storage.GetMemory(), units.Default); err != nil {
+				return false
+			}
+		}
+	}
+	return hasAtLeast(capacity, minCapacity)
+}
+
+func isQualifiedScreen(screen *pb.Screen, filter *pb.Filter) bool {
+	if filter.GetMinScreenSizeInch() > 0 && screen.GetSizeInch() < filter.GetMinScreenSizeInch() {
+		return false
+	}
+	if filter.GetMaxScreenSizeInch() > 0 && screen.GetSizeInch() > filter.GetMaxScreenSizeInch() {
+		return false
+	}
+
+	resolution := screen.GetResolution()
+	if minResolution := filter.GetMinResolution(); minResolution != nil &&
+		(resolution.GetWidth() < minResolution.GetWidth() || resolution.GetHeight() < minResolution.GetHeight()) {
+		return false
+	}
+	if maxResolution := filter.GetMaxResolution(); maxResolution != nil &&
+		(resolution.GetWidth() > maxResolution.GetWidth() || resolution.GetHeight() > maxResolution.GetHeight()) {
+		return false
+	}
+
+	if len(filter.GetPanels()) > 0 && !slices.Contains(filter.GetPanels(), screen.GetPanel()) {
+		return false
+	}
+	if filter != nil && filter.Multitouch != nil && screen.GetMultitouch() != filter.GetMultitouch() {
+		return false
+	}
+	return true
+}
+
+func isQualifiedKeyboard(keyboard *pb.Keyboard, filter *pb.Filter) bool {
+	if len(filter.GetKeyboardLayouts()) > 0 && !slices.Contains(filter.GetKeyboardLayouts(), keyboard.GetLayout()) {
+		return false
+	}
+	if filter != nil && filter.KeyboardBacklit != nil && keyboard.GetBacklit() != filter.GetKeyboardBacklit() {
+		return false
+	}
+	return true
+}
+
+// hasAtLeast reports whether the memory is at least min. An empty min accepts any memory,
+// otherwise memories of unknown units, which validation rejects, never qualify.
+func hasAtLeast(memory *pb.Memory, min *pb.Memory) bool {
+	if min.GetValue() == 0 {
+		return true
+	}
+	result, err := units.Compare(memory, min, units.Default)
+	return err == nil && result >= 0
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsQualifiedLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Lenovo"
+	laptop.Name = "Thinkpad X1"
+	laptop.PriceUsd = 2000
+	laptop.Gpus = []*pb.GPU{{Brand: "NVIDIA", Name: "RTX 2070", Memory: &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}}}
+	laptop.Storages = []*pb.Storage{
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 512, Unit: pb.Memory_GIGABYTE}},
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 512, Unit: pb.Memory_GIGABYTE}},
+	}
+	laptop.Screen = &pb.Screen{
+		SizeInch:   14,
+		Resolution: &pb.Screen_Resolution{Width: 2560, Height: 1440},
+		Panel:      pb.Screen_IPS,
+		Multitouch: true,
+	}
+	laptop.Keyboard = &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: true}
+	laptop.ReleaseYear = 2019
+	laptop.Weight = &pb.Laptop_WeightKg{WeightKg: 1.5}
+
+	testCases := []struct {
+		name      string
+		filter    func(filter *pb.Filter)
+		qualified bool
+	}{
+		{
+			name:      "base_filter",
+			filter:    func(filter *pb.Filter) {},
+			qualified: true,
+		},
+		{
+			name:      "brand_ignoring_case",
+			filter:    func(filter *pb.Filter) { filter.Brands = []string{"dell", "LENOVO"} },
+			qualified: true,
+		},
+		{
+			name:      "other_brand",
+			filter:    func(filter *pb.Filter) { filter.Brands = []string{"Dell"} },
+			qualified: false,
+		},
+		{
+			name:      "other_name",
+			filter:    func(filter *pb.Filter) { filter.Names = []string{"XPS"} },
+			qualified: false,
+		},
+		{
+			name: "gpu_memory_and_brand",
+			filter: func(filter *pb.Filter) {
+				filter.MinGpuMemory = &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}
+				filter.GpuBrands = []string{"nvidia"}
+			},
+			qualified: true,
+		},
+		{
+			name:      "gpu_memory_too_small",
+			filter:    func(filter *pb.Filter) { filter.MinGpuMemory = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE} },
+			qualified: false,
+		},
+		{
+			name:      "total_ssd_capacity",
+			filter:    func(filter *pb.Filter) { filter.MinSsdCapacity = &pb.Memory{Value: 1024, Unit: pb.Memory_GIGABYTE} },
+			qualified: true,
+		},
+		{
+			name:      "total_ssd_capacity_in_terabytes",
+			filter:    func(filter *pb.Filter) { filter.MinSsdCapacity = &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE} },
+			qualified: true,
+		},
+		{
+			name:      "ssd_capacity_of_unknown_unit",
+			filter:    func(filter *pb.Filter) { filter.MinSsdCapacity = &pb.Memory{Value: 1} },
+			qualified: false,
+		},
+		{
+			name:      "missing_hdd",
+			filter:    func(filter *pb.Filter) { filter.MinHddCapacity = &pb.Memory{Value: 1, Unit: pb.Memory_GIGABYTE} },
+			qualified: false,
+		},
+		{
+			name: "screen_size_range",
+			filter: func(filter *pb.Filter) {
+				filter.MinScreenSizeInch = 13
+				filter.MaxScreenSizeInch = 14
+			},
+			qualified: true,
+		},
+		{
+			name:      "screen_too_small",
+			filter:    func(filter *pb.Filter) { filter.MinScreenSizeInch = 15 },
+			qualified: false,
+		},
+		{
+			name:      "resolution_too_low",
+			filter:    func(filter *pb.Filter) { filter.MinResolution = &pb.Screen_Resolution{Width: 3840, Height: 2160} },
+			qualified: false,
+		},
+		{
+			name:      "resolution_too_high",
+			filter:    func(filter *pb.Filter) { filter.MaxResolution = &pb.Screen_Resolution{Width: 1920, Height: 1080} },
+			qualified: false,
+		},
+		{
+			name:      "other_panel",
+			filter:    func(filter *pb.Filter) { filter.Panels = []pb.Screen_Panel{pb.Screen_OLED} },
+			qualified: false,
+		},
+		{
+			name:      "not_multitouch",
+			filter:    func(filter *pb.Filter) { filter.Multitouch = proto.Bool(false) },
+			qualified: false,
+		},
+		{
+			name: "keyboard",
+			filter: func(filter *pb.Filter) {
+				filter.KeyboardLayouts = []pb.Keyboard_Layout{pb.Keyboard_QWERTY, pb.Keyboard_AZERTY}
+				filter.KeyboardBacklit = proto.Bool(true)
+			},
+			qualified: true,
+		},
+		{
+			name:      "other_keyboard_layout",
+			filter:    func(filter *pb.Filter) { filter.KeyboardLayouts = []pb.Keyboard_Layout{pb.Keyboard_QWERTZ} },
+			qualified: false,
+		},
+		{
+			name: "release_year_range",
+			filter: func(filter *pb.Filter) {
+				filter.MinReleaseYear = 2018
+				filter.MaxReleaseYear = 2019
+			},
+			qualified: true,
+		},
+		{
+			name:      "too_old",
+			filter:    func(filter *pb.Filter) { filter.MinReleaseYear = 2020 },
+			qualified: false,
+		},
+		{
+			name:      "too_heavy_in_kg",
+			filter:    func(filter *pb.Filter) { filter.MaxWeight = &pb.Filter_MaxWeightKg{MaxWeightKg: 1.2} },
+			qualified: false,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := &pb.Filter{
+				MaxPriceUsd: 3000,
+				MinCpuCores: laptop.Cpu.NumberCores,
+				MinCpuGhz:   laptop.Cpu.MinGhz,
+				MinRam:      laptop.Ram,
+			}
+			tc.filter(filter)
+			require.Equal(t, tc.qualified, isQualifiedLaptop(laptop, filter))
+		})
+	}
+}
@@ -3,29 +3,41 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"os"
 
 	"github.com/google/uuid"
 	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/query"
+	"github.com/pokala15/pcbook/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-const maxImageSize = 1 << 20
+const (
+	maxImageSize   = 1 << 20
+	imageChunkSize = 32 << 10
+	maxQueryLength = 4 << 10 // bytes of a search query, far more than any written by hand
+)
 
 type LaptopServer struct {
 	pb.UnimplementedLaptopServiceServer
 	laptopStore LaptopStore
 	imageStore  ImageStore
+	uploadStore UploadStore
 }
 
-func NewLaptopServer(store LaptopStore, imageStore ImageStore) *LaptopServer {
+func NewLaptopServer(store LaptopStore, imageStore ImageStore, uploadStore UploadStore) *LaptopServer {
 	return &LaptopServer{
 		laptopStore: store,
 		imageStore:  imageStore,
+		uploadStore: uploadStore,
 	}
 }
 
@@ -34,7 +46,11 @@ func (service *LaptopServer) CreateLaptop(
 	request *pb.CreateLaptopRequest,
 ) (response *pb.CreateLaptopResponse, err error) {
 	laptop := request.GetLaptop()
-	log.Printf("receive create laptop request with id: %s", laptop.Id)
+	log.Printf("receive create laptop request with id: %s", laptop.GetId())
+
+	if err := validation.ValidateLaptop(laptop); err != nil {
+		return nil, invalidArgumentError("laptop", err)
+	}
 
 	if len(laptop.Id) > 0 {
 		if err := uuid.Validate(laptop.Id); err != nil {
@@ -54,11 +70,7 @@ func (service *LaptopServer) CreateLaptop(
 
 	// store the laptop object in storage
 	if err := service.laptopStore.Save(laptop); err != nil {
-		code := codes.Internal
-		if errors.Is(err, ErrAlreadyExists) {
-			code = codes.AlreadyExists
-		}
-		return nil, status.Errorf(code, "failed to save laptop: %v", err)
+		return nil, status.Errorf(storeErrorCode(err), "failed to save laptop: %v", err)
 	} else {
 		log.Printf("laptop is successfully saved with id: %v", laptop.Id)
 	}
@@ -67,6 +79,54 @@ func (service *LaptopServer) CreateLaptop(
 	}, nil
 }
 
+// storeErrorCode returns the code of an error returned by a LaptopStore
+func storeErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, ErrInvalidFieldMask), errors.Is(err, ErrLaptopTooLarge):
+		return codes.InvalidArgument
+	case errors.Is(err, ErrVersionMismatch):
+		return codes.Aborted
+	case errors.Is(err, ErrInvalidOffset):
+		return codes.OutOfRange
+	case errors.Is(err, ErrUploadFinished):
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// invalidArgumentError returns an InvalidArgument error carrying the violations of the
+// given field of a request as google.rpc.BadRequest details
+func invalidArgumentError(field string, err error) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s: %v", field, err))
+
+	var validationErr *validation.Error
+	if !errors.As(err, &validationErr) {
+		return st.Err()
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range validationErr.Violations {
+		path := field
+		if violation.Field != "" {
+			path += "." + violation.Field
+		}
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       path,
+			Description: violation.Description,
+		})
+	}
+
+	detailed, detailErr := st.WithDetails(badRequest)
+	if detailErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
 func validateContext(ctx context.Context) error {
 	switch ctx.Err() {
 	case context.Canceled:
@@ -86,9 +146,33 @@ func (service *LaptopServer) SearchLaptop(request *pb.SearchLaptopRequest,
 	stream grpc.ServerStreamingServer[pb.SearchLaptopResponse],
 ) error {
 	filter := request.GetFilter()
-	log.Printf("receive search laptop with filter: %v", filter)
+	if len(request.GetQuery()) > maxQueryLength {
+		return status.Errorf(codes.InvalidArgument, "query is longer than %d bytes", maxQueryLength)
+	}
+	log.Printf("receive search laptop with filter: %v, query: %q, text: %q, sort by: %v",
+		filter, request.GetQuery(), request.GetText(), request.GetSortBy())
+
+	if err := validation.ValidateFilter(filter); err != nil {
+		return invalidArgumentError("filter", err)
+	}
+	// text without words would not restrict the search and match every laptop
+	if request.GetText() != "" && len(tokenize(request.GetText())) == 0 {
+		return status.Error(codes.InvalidArgument, "text has no letters or digits to search for")
+	}
 
-	err := service.laptopStore.Search(filter, stream.Context(),
+	options := SearchOptions{
+		Text:       request.GetText(),
+		SortBy:     request.GetSortBy(),
+		MaxResults: int(request.GetMaxResults()),
+	}
+	if request.GetQuery() != "" {
+		predicate, err := query.Parse(request.GetQuery())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid query: %v", err)
+		}
+		options.Query = predicate
+	}
+	err := service.laptopStore.Search(filter, options, stream.Context(),
 		func(laptop *pb.Laptop) error {
 			res := &pb.SearchLaptopResponse{Laptop: laptop}
 			err := stream.Send(res)
@@ -101,58 +185,514 @@ func (service *LaptopServer) SearchLaptop(request *pb.SearchLaptopRequest,
 			return nil
 		})
 	if err != nil {
-		return status.Errorf(codes.Internal, "error while searching for laptop: %v", err)
+		return status.Errorf(searchErrorCode(err), "error while searching for laptop: %v", err)
 	}
 	return nil
 }
 
+// searchErrorCode returns the code of an error that stopped a search: failures to send
+// keep the code of the stream, and a cancelled request is not an internal error
+func searchErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Code()
+	}
+	return storeErrorCode(err)
+}
+
+func (service *LaptopServer) GetLaptop(
+	ctx context.Context,
+	request *pb.GetLaptopRequest,
+) (*pb.GetLaptopResponse, error) {
+	laptopId := request.GetId()
+	log.Printf("receive get laptop request with id: %s", laptopId)
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := service.laptopStore.FindById(laptopId)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", laptopId, err)
+	}
+
+	return &pb.GetLaptopResponse{
+		Laptop: laptop,
+	}, nil
+}
+
+func (service *LaptopServer) UpdateLaptop(
+	ctx context.Context,
+	request *pb.UpdateLaptopRequest,
+) (*pb.UpdateLaptopResponse, error) {
+	laptop := request.GetLaptop()
+	log.Printf("receive update laptop request with id: %s, mask: %v", laptop.GetId(), request.GetUpdateMask().GetPaths())
+
+	if laptop == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	updated, err := service.laptopStore.Update(laptop, request.GetUpdateMask(), request.GetExpectedVersion())
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		// the merged laptop is checked like a new one
+		return nil, invalidArgumentError("laptop", err)
+	} else if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to update laptop: %v", err)
+	}
+	log.Printf("laptop is successfully updated with id: %v", updated.Id)
+
+	return &pb.UpdateLaptopResponse{
+		Laptop: updated,
+	}, nil
+}
+
+func (service *LaptopServer) DeleteLaptop(
+	ctx context.Context,
+	request *pb.DeleteLaptopRequest,
+) (*pb.DeleteLaptopResponse, error) {
+	laptopId := request.GetId()
+	log.Printf("receive delete laptop request with id: %s", laptopId)
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := service.laptopStore.Delete(laptopId, request.GetExpectedVersion()); err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to delete laptop: %v", err)
+	}
+
+	// images can't be served without their laptop, so remove them as well
+	if service.imageStore != nil {
+		if err := service.imageStore.DeleteByLaptopId(laptopId); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to delete images of laptop: %v", err)
+		}
+	}
+	log.Printf("laptop is successfully deleted with id: %v", laptopId)
+
+	return &pb.DeleteLaptopResponse{}, nil
+}
+
+func (service *LaptopServer) ListLaptops(
+	ctx context.Context,
+	request *pb.ListLaptopsRequest,
+) (*pb.ListLaptopsResponse, error) {
+	log.Printf("receive list laptops request with page size: %d", request.GetPageSize())
+
+	lastId, err := decodePageToken(request.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page token: %v", err)
+	}
+
+	pageSize := int(request.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	// fetch one extra laptop to find out whether there is a next page
+	laptops, err := service.laptopStore.List(lastId, pageSize+1)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to list laptops: %v", err)
+	}
+
+	response := &pb.ListLaptopsResponse{}
+	if len(laptops) > pageSize {
+		laptops = laptops[:pageSize]
+		response.NextPageToken = encodePageToken(laptops[pageSize-1].Id)
+	}
+	response.Laptops = laptops
+
+	return response, nil
+}
+
+func (service *LaptopServer) AggregateLaptops(
+	ctx context.Context,
+	request *pb.AggregateLaptopsRequest,
+) (*pb.AggregateLaptopsResponse, error) {
+	filter := request.GetFilter()
+	log.Printf("receive aggregate laptops request with filter: %v", filter)
+
+	if err := validation.ValidateFilter(filter); err != nil {
+		return nil, invalidArgumentError("filter", err)
+	}
+
+	aggregator := newLaptopAggregator()
+	err := service.laptopStore.Search(filter, SearchOptions{}, ctx,
+		func(laptop *pb.Laptop) error {
+			aggregator.add(laptop)
+			return nil
+		})
+	if err != nil {
+		return nil, status.Errorf(searchErrorCode(err), "error while aggregating laptops: %v", err)
+	}
+
+	return aggregator.response(), nil
+}
+
 func (service *LaptopServer) UploadImage(stream grpc.ClientStreamingServer[pb.UploadImageRequest,
 	pb.UploadImageResponse]) error {
-	imageData := bytes.Buffer{}
-	imageSize := 0
-
 	request, err := stream.Recv()
 	if err != nil {
 		return status.Errorf(codes.Unknown, "failed to read streaming data: %v", err)
 	}
+	if uploadId := request.GetUploadId(); uploadId != "" {
+		return service.resumeUpload(stream, uploadId, request.GetOffset())
+	}
+	if err := validation.ValidateImageInfo(request.GetInfo()); err != nil {
+		return invalidArgumentError("info", err)
+	}
 	laptopId := request.GetInfo().GetLaptopId()
 	imageType := request.GetInfo().GetImageType()
 
-	laptop, err := service.laptopStore.FindById(laptopId)
+	if _, err := service.laptopStore.FindById(laptopId); err != nil {
+		return status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", laptopId, err)
+	}
+
+	imageData := &imageChunkReader{stream: stream}
+	imageId, err := service.imageStore.Save(laptopId, imageType, imageData)
+	if imageData.err != nil {
+		return imageData.err
+	}
 	if err != nil {
-		return status.Errorf(codes.Internal, "error while fetching laptop: %v", err)
-	} else if laptop == nil {
-		return status.Errorf(codes.InvalidArgument, "laptop doesn't exist with id: %v", laptopId)
+		return saveImageError(err)
+	}
+	if err := service.checkLaptopKept(laptopId); err != nil {
+		return err
+	}
+	if request.GetInfo().GetPrimary() {
+		if err := service.imageStore.SetPrimary(imageId); err != nil {
+			return status.Errorf(codes.Internal, "unable to make the image primary: %v", err)
+		}
 	}
 
-	for {
-		request, err := stream.Recv()
+	return stream.SendAndClose(&pb.UploadImageResponse{
+		ImageId: imageId,
+		Size:    uint32(imageData.size),
+	})
+}
+
+// checkLaptopKept is called after an image of the laptop is saved. If the laptop was deleted since
+// it was found, its images may have been deleted before the image was saved, so they are deleted again.
+func (service *LaptopServer) checkLaptopKept(laptopId string) error {
+	_, err := service.laptopStore.FindById(laptopId)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		if err := service.imageStore.DeleteByLaptopId(laptopId); err != nil {
+			return status.Errorf(codes.Internal, "failed to delete images of deleted laptop: %v", err)
+		}
+	}
+	return status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", laptopId, err)
+}
+
+// saveImageError returns InvalidArgument if the image store rejected the data, Internal otherwise
+func saveImageError(err error) error {
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		return invalidArgumentError("image", validationErr)
+	}
+	return status.Errorf(codes.Internal, "Unable save the image: %v", err)
+}
+
+// resumeUpload writes the chunks of the stream to the upload session from offset on
+func (service *LaptopServer) resumeUpload(stream grpc.ClientStreamingServer[pb.UploadImageRequest,
+	pb.UploadImageResponse], uploadId string, offset uint64) error {
+	log.Printf("receive chunks of upload %s from offset %d", uploadId, offset)
+
+	imageData := &imageChunkReader{stream: stream, size: int(offset)}
+	received, err := service.uploadStore.Write(uploadId, int64(offset), imageData)
+	if imageData.err != nil {
+		return imageData.err
+	}
+	if err != nil {
+		return status.Errorf(storeErrorCode(err), "failed to write upload %s: %v", uploadId, err)
+	}
+
+	return stream.SendAndClose(&pb.UploadImageResponse{
+		Size: uint32(received),
+	})
+}
+
+// imageChunkReader reads the chunks of an upload stream as they arrive. It fails with a status
+// error, also kept in err, if the stream breaks, the upload is cancelled or the image is too big.
+type imageChunkReader struct {
+	stream grpc.ClientStreamingServer[pb.UploadImageRequest, pb.UploadImageResponse]
+	chunk  []byte
+	size   int
+	err    error
+}
+
+func (reader *imageChunkReader) Read(p []byte) (int, error) {
+	for len(reader.chunk) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		if err := validateContext(reader.stream.Context()); err != nil {
+			reader.err = err
+			return 0, err
+		}
+		request, err := reader.stream.Recv()
 		if err == io.EOF {
-			break
+			return 0, io.EOF
 		} else if err != nil {
-			return status.Errorf(codes.Unknown, "failed to read streaming data: %v", err)
+			reader.err = status.Errorf(codes.Unknown, "failed to read streaming data: %v", err)
+			return 0, reader.err
+		}
+		reader.chunk = request.GetChunkData()
+		reader.size += len(reader.chunk)
+		if reader.size > maxImageSize {
+			reader.err = status.Errorf(codes.Unknown, "image is too big : %v > %v", reader.size, maxImageSize)
+			return 0, reader.err
 		}
+	}
+	n := copy(p, reader.chunk)
+	reader.chunk = reader.chunk[n:]
+	return n, nil
+}
+
+func (service *LaptopServer) ListImages(
+	ctx context.Context,
+	request *pb.ListImagesRequest,
+) (*pb.ListImagesResponse, error) {
+	laptopId := request.GetLaptopId()
+	log.Printf("receive list images request with laptop id: %s", laptopId)
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := service.laptopStore.FindById(laptopId); err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", laptopId, err)
+	}
+
+	infos, err := service.imageStore.List(laptopId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list images: %v", err)
+	}
+
+	response := &pb.ListImagesResponse{}
+	for _, info := range infos {
+		response.Images = append(response.Images, &pb.Image{
+			Id:        info.Id,
+			LaptopId:  info.LaptopId,
+			ImageType: *info.Type,
+			Size:      uint32(info.Size),
+			Primary:   info.Primary,
+			Width:     uint32(info.Width),
+			Height:    uint32(info.Height),
+		})
+	}
+	return response, nil
+}
+
+func (service *LaptopServer) DownloadImage(request *pb.DownloadImageRequest,
+	stream grpc.ServerStreamingServer[pb.DownloadImageResponse],
+) error {
+	imageId := request.GetImageId()
+	log.Printf("receive download image request with id: %s", imageId)
+
+	info, reader, err := service.imageStore.Open(imageId)
+	if err != nil {
+		code := storeErrorCode(err)
+		// the file is gone if the image was deleted since it was looked up
+		if errors.Is(err, os.ErrNotExist) {
+			code = codes.NotFound
+		}
+		return status.Errorf(code, "failed to open image with id %s: %v", imageId, err)
+	}
+	defer reader.Close()
+
+	err = stream.Send(&pb.DownloadImageResponse{
+		Info: &pb.ImageInfo{
+			LaptopId:  info.LaptopId,
+			ImageType: *info.Type,
+			Primary:   info.Primary,
+		},
+		Size: uint32(info.Size),
+	})
+	if err != nil {
+		return status.Errorf(searchErrorCode(err), "failed to send image info: %v", err)
+	}
+
+	buffer := make([]byte, imageChunkSize)
+	for {
 		if err := validateContext(stream.Context()); err != nil {
 			return err
 		}
-		chunk := request.GetChunkData()
-		chunkSize, err := imageData.Write(chunk)
-		if err != nil {
-			return status.Errorf(codes.Unknown, "unable to write the image to file: %v", err)
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if err := stream.Send(&pb.DownloadImageResponse{ChunkData: buffer[:n]}); err != nil {
+				return status.Errorf(searchErrorCode(err), "failed to send image chunk: %v", err)
+			}
 		}
-		imageSize += chunkSize
-		if imageSize > maxImageSize {
-			return status.Errorf(codes.Unknown, "image is too big : %v > %v", imageSize, maxImageSize)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return status.Errorf(codes.Internal, "failed to read image: %v", err)
 		}
 	}
+	log.Printf("image is successfully sent with id: %v", imageId)
+	return nil
+}
 
-	imageId, err := service.imageStore.Save(laptopId, imageType, imageData)
+func (service *LaptopServer) StartUpload(
+	ctx context.Context,
+	request *pb.StartUploadRequest,
+) (*pb.StartUploadResponse, error) {
+	laptopId := request.GetInfo().GetLaptopId()
+	log.Printf("receive start upload request for laptop with id: %s", laptopId)
+
+	if err := validation.ValidateImageInfo(request.GetInfo()); err != nil {
+		return nil, invalidArgumentError("info", err)
+	}
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := service.laptopStore.FindById(laptopId); err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", laptopId, err)
+	}
+	uploadId, err := service.uploadStore.Start(laptopId, request.GetInfo().GetImageType(), request.GetInfo().GetPrimary())
 	if err != nil {
-		return status.Errorf(codes.Internal, "Unable save the image: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to start upload: %v", err)
 	}
 
-	return stream.SendAndClose(&pb.UploadImageResponse{
+	return &pb.StartUploadResponse{UploadId: uploadId}, nil
+}
+
+func (service *LaptopServer) QueryUpload(
+	ctx context.Context,
+	request *pb.QueryUploadRequest,
+) (*pb.QueryUploadResponse, error) {
+	uploadId := request.GetUploadId()
+	log.Printf("receive query upload request with id: %s", uploadId)
+
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := service.uploadStore.Find(uploadId)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "failed to find upload with id %s: %v", uploadId, err)
+	}
+
+	return &pb.QueryUploadResponse{Received: uint64(upload.Received)}, nil
+}
+
+// FinishUpload stores the image of the upload session once its checksum is verified.
+// Finishing an upload again returns the same image, so that clients can retry.
+func (service *LaptopServer) FinishUpload(
+	ctx context.Context,
+	request *pb.FinishUploadRequest,
+) (*pb.FinishUploadResponse, error) {
+	uploadId := request.GetUploadId()
+	log.Printf("receive finish upload request with id: %s", uploadId)
+
+	if len(request.GetSha256()) != sha256.Size {
+		return nil, status.Errorf(codes.InvalidArgument, "sha256 must be %d bytes long", sha256.Size)
+	}
+	if err := validateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	imageId, upload, err := service.storeUpload(uploadId, request.GetSha256())
+	if errors.Is(err, ErrUploadFinished) {
+		imageId, upload, err = service.findFinishedUpload(uploadId, request.GetSha256())
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("upload %s is stored as image with id: %s", uploadId, imageId)
+
+	return &pb.FinishUploadResponse{
 		ImageId: imageId,
-		Size:    uint32(imageSize),
-	})
+		Size:    uint32(upload.Received),
+	}, nil
+}
+
+// storeUpload saves the data of the upload to the image store if it has the checksum
+func (service *LaptopServer) storeUpload(uploadId string, checksum []byte) (string, *Upload, error) {
+	upload, imageData, err := service.uploadStore.Open(uploadId)
+	if errors.Is(err, ErrUploadFinished) {
+		return "", nil, err
+	} else if err != nil {
+		return "", nil, status.Errorf(storeErrorCode(err), "failed to open upload with id %s: %v", uploadId, err)
+	}
+	defer imageData.Close()
+
+	if _, err := service.laptopStore.FindById(upload.LaptopId); err != nil {
+		return "", nil, status.Errorf(storeErrorCode(err), "failed to find laptop with id %s: %v", upload.LaptopId, err)
+	}
+
+	if err := checkSha256(imageData, checksum); err != nil {
+		return "", nil, err
+	}
+	if _, err := imageData.Seek(0, io.SeekStart); err != nil {
+		return "", nil, status.Errorf(codes.Internal, "failed to read upload: %v", err)
+	}
+
+	imageId, err := service.imageStore.Save(upload.LaptopId, upload.Type, imageData)
+	if err != nil {
+		return "", nil, saveImageError(err)
+	}
+	if err := service.checkLaptopKept(upload.LaptopId); err != nil {
+		return "", nil, err
+	}
+	if upload.Primary {
+		if err := service.imageStore.SetPrimary(imageId); err != nil {
+			return "", nil, status.Errorf(codes.Internal, "unable to make the image primary: %v", err)
+		}
+	}
+	// the upload is finished before its lock is released, so that a retry can't store it twice
+	if err := imageData.Finish(imageId); err != nil {
+		log.Printf("failed to drop the data of upload %s: %v", uploadId, err)
+	}
+	return imageId, upload, nil
+}
+
+// findFinishedUpload returns the image a finished upload is stored as, if it has the checksum
+func (service *LaptopServer) findFinishedUpload(uploadId string, checksum []byte) (string, *Upload, error) {
+	upload, err := service.uploadStore.Find(uploadId)
+	if err != nil {
+		return "", nil, status.Errorf(storeErrorCode(err), "failed to find upload with id %s: %v", uploadId, err)
+	}
+
+	_, imageData, err := service.imageStore.Open(upload.ImageId)
+	if err != nil {
+		return "", nil, status.Errorf(storeErrorCode(err), "failed to open image with id %s: %v", upload.ImageId, err)
+	}
+	defer imageData.Close()
+
+	if err := checkSha256(imageData, checksum); err != nil {
+		return "", nil, err
+	}
+	return upload.ImageId, upload, nil
+}
+
+// checkSha256 fails with DataLoss unless the data has the checksum
+func checkSha256(data io.Reader, checksum []byte) error {
+	hash := sha256.New()
+	size, err := io.Copy(hash, data)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to read upload: %v", err)
+	}
+	if !bytes.Equal(hash.Sum(nil), checksum) {
+		return status.Errorf(codes.DataLoss, "sha256 of the %d bytes received is %x, not %x", size, hash.Sum(nil), checksum)
+	}
+	return nil
 }
@@ -3,12 +3,17 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/pokala15/pcbook/pb"
 	"github.com/pokala15/pcbook/sample"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func TestServerCreateLaptop(t *testing.T) {
@@ -27,6 +32,9 @@ func TestServerCreateLaptop(t *testing.T) {
 	duplicateLaptop := sample.NewLaptop()
 	duplicateLaptop.Id = laptop.Id
 
+	laptopNoCpu := sample.NewLaptop()
+	laptopNoCpu.Cpu = nil
+
 	testCases := []struct {
 		name   string
 		laptop *pb.Laptop
@@ -57,6 +65,12 @@ func TestServerCreateLaptop(t *testing.T) {
 			store:  duplicateStore,
 			code:   codes.AlreadyExists,
 		},
+		{
+			name:   "failure_without_cpu",
+			laptop: laptopNoCpu,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.InvalidArgument,
+		},
 	}
 
 	for i := range testCases {
@@ -64,7 +78,7 @@ func TestServerCreateLaptop(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			response, err := NewLaptopServer(tc.store, nil).CreateLaptop(
+			response, err := NewLaptopServer(tc.store, nil, nil).CreateLaptop(
 				context.Background(),
 				&pb.CreateLaptopRequest{
 					Laptop: tc.laptop,
@@ -85,3 +99,330 @@ func TestServerCreateLaptop(t *testing.T) {
 		})
 	}
 }
+
+func TestServerCreateLaptopViolations(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Cpu.NumberThreads = laptop.Cpu.NumberCores - 1
+	laptop.PriceUsd = -1
+
+	_, err := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil).CreateLaptop(
+		context.Background(),
+		&pb.CreateLaptopRequest{Laptop: laptop},
+	)
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	var fields []string
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{"laptop.cpu.number_threads", "laptop.price_usd"}, fields)
+
+	_, err = NewLaptopServer(NewInMemoryLaptopStore(), nil, nil).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerUpdateLaptop(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	update := sample.NewLaptop()
+	update.Id = laptop.Id
+	// min_ghz is updated alone, so it must stay within the stored max_ghz
+	update.Cpu.MinGhz = laptop.Cpu.MaxGhz
+
+	unknownLaptop := sample.NewLaptop()
+
+	testCases := []struct {
+		name   string
+		laptop *pb.Laptop
+		paths  []string
+		code   codes.Code
+	}{
+		{
+			name:   "success_with_price",
+			laptop: update,
+			paths:  []string{"price_usd"},
+			code:   codes.OK,
+		},
+		{
+			name:   "success_with_nested_field",
+			laptop: update,
+			paths:  []string{"ram", "cpu.min_ghz"},
+			code:   codes.OK,
+		},
+		{
+			name:   "failure_with_unknown_path",
+			laptop: update,
+			paths:  []string{"colour"},
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_with_immutable_path",
+			laptop: update,
+			paths:  []string{"id"},
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_with_unknown_id",
+			laptop: unknownLaptop,
+			paths:  []string{"price_usd"},
+			code:   codes.NotFound,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := NewLaptopServer(store, nil, nil).UpdateLaptop(
+				context.Background(),
+				&pb.UpdateLaptopRequest{
+					Laptop:     tc.laptop,
+					UpdateMask: &fieldmaskpb.FieldMask{Paths: tc.paths},
+				},
+			)
+			if tc.code == codes.OK {
+				require.NoError(t, err)
+				require.NotNil(t, response)
+				updated := response.GetLaptop()
+				require.Equal(t, laptop.Id, updated.Id)
+				require.Equal(t, laptop.Name, updated.Name)
+				require.True(t, updated.UpdatedAt.AsTime().After(laptop.UpdatedAt.AsTime()))
+				for _, path := range tc.paths {
+					switch path {
+					case "price_usd":
+						require.Equal(t, tc.laptop.PriceUsd, updated.PriceUsd)
+					case "ram":
+						require.True(t, proto.Equal(tc.laptop.Ram, updated.Ram))
+					case "cpu.min_ghz":
+						require.Equal(t, tc.laptop.Cpu.MinGhz, updated.Cpu.MinGhz)
+						require.Equal(t, laptop.Cpu.MaxGhz, updated.Cpu.MaxGhz)
+					}
+				}
+			} else {
+				require.Error(t, err)
+				require.Nil(t, response)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.code, st.Code())
+			}
+		})
+	}
+}
+
+func TestServerUpdateLaptopViolations(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	update := sample.NewLaptop()
+	update.Id = laptop.Id
+	update.Cpu.MinGhz = laptop.Cpu.MaxGhz + 1
+	update.PriceUsd = -1
+
+	_, err = NewLaptopServer(store, nil, nil).UpdateLaptop(
+		context.Background(),
+		&pb.UpdateLaptopRequest{
+			Laptop:     update,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"cpu.min_ghz", "price_usd"}},
+		},
+	)
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	var fields []string
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{"laptop.cpu.max_ghz", "laptop.price_usd"}, fields)
+
+	stored, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stored.Version)
+}
+
+func TestServerLaptopVersionConflict(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	server := NewLaptopServer(store, nil, nil)
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}
+
+	response, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:          laptop,
+		UpdateMask:      mask,
+		ExpectedVersion: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), response.GetLaptop().GetVersion())
+
+	// a second editor still holding version 1 must not overwrite the update
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:          laptop,
+		UpdateMask:      mask,
+		ExpectedVersion: 1,
+	})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{
+		Id:              laptop.Id,
+		ExpectedVersion: 1,
+	})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{
+		Id:              laptop.Id,
+		ExpectedVersion: 2,
+	})
+	require.NoError(t, err)
+}
+
+// stalledSearchStream is a search stream whose client stopped reading
+type stalledSearchStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	stalled chan struct{}
+	release chan struct{}
+}
+
+func (stream *stalledSearchStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *stalledSearchStream) Send(response *pb.SearchLaptopResponse) error {
+	close(stream.stalled)
+	<-stream.release
+	return nil
+}
+
+func TestServerCreateLaptopWhileSearchStalled(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+	server := NewLaptopServer(store, nil, nil)
+
+	stream := &stalledSearchStream{
+		ctx:     context.Background(),
+		stalled: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	searchDone := make(chan error)
+	go func() {
+		searchDone <- server.SearchLaptop(&pb.SearchLaptopRequest{}, stream)
+	}()
+	<-stream.stalled
+
+	createDone := make(chan error)
+	go func() {
+		_, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{
+			Laptop: sample.NewLaptop(),
+		})
+		createDone <- err
+	}()
+
+	select {
+	case err := <-createDone:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("create laptop is blocked by a stalled search stream")
+	}
+
+	close(stream.release)
+	require.NoError(t, <-searchDone)
+}
+
+// failingSearchStream is a search stream that fails to send
+type failingSearchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	err  error
+	sent int
+}
+
+func (stream *failingSearchStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *failingSearchStream) Send(response *pb.SearchLaptopResponse) error {
+	stream.sent++
+	return stream.err
+}
+
+func TestServerSearchLaptopStreamError(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < 3; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+	server := NewLaptopServer(store, nil, nil)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		sent int
+		code codes.Code
+	}{
+		{
+			name: "client_unavailable",
+			ctx:  context.Background(),
+			err:  status.Error(codes.Unavailable, "transport is closing"),
+			sent: 1,
+			code: codes.Unavailable,
+		},
+		{
+			name: "client_cancelled",
+			ctx:  context.Background(),
+			err:  status.Error(codes.Canceled, "context canceled"),
+			sent: 1,
+			code: codes.Canceled,
+		},
+		{
+			name: "request_cancelled",
+			ctx:  cancelledCtx,
+			sent: 0,
+			code: codes.Canceled,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := &failingSearchStream{ctx: tc.ctx, err: tc.err}
+			err := server.SearchLaptop(&pb.SearchLaptopRequest{}, stream)
+			require.Equal(t, tc.code, status.Code(err))
+			require.Equal(t, tc.sent, stream.sent)
+		})
+	}
+}
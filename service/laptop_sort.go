@@ -0,0 +1,65 @@
+package service
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+// sortLaptops orders the laptops in place by the requested field,
+// breaking ties by id so that the order is stable across searches.
+func sortLaptops(laptops []*pb.Laptop, sortBy *pb.Sort) {
+	var compare func(a *pb.Laptop, b *pb.Laptop) int
+	switch sortBy.GetField() {
+	case pb.Sort_PRICE:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return cmp.Compare(a.GetPriceUsd(), b.GetPriceUsd())
+		}
+	case pb.Sort_CPU_GHZ:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return cmp.Compare(a.GetCpu().GetMinGhz(), b.GetCpu().GetMinGhz())
+		}
+	case pb.Sort_RAM:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return cmp.Compare(units.SaturatedBits(a.GetRam()), units.SaturatedBits(b.GetRam()))
+		}
+	case pb.Sort_RELEASE_YEAR:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return cmp.Compare(a.GetReleaseYear(), b.GetReleaseYear())
+		}
+	case pb.Sort_WEIGHT:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return cmp.Compare(units.WeightKg(a), units.WeightKg(b))
+		}
+	case pb.Sort_UPDATED_AT:
+		compare = func(a *pb.Laptop, b *pb.Laptop) int {
+			return a.GetUpdatedAt().AsTime().Compare(b.GetUpdatedAt().AsTime())
+		}
+	default:
+		return
+	}
+
+	descending := sortBy.GetDirection() == pb.Sort_DESCENDING
+	slices.SortFunc(laptops, func(a *pb.Laptop, b *pb.Laptop) int {
+		result := compare(a, b)
+		if result == 0 {
+			result = cmp.Compare(a.GetId(), b.GetId())
+		}
+		if descending {
+			return -result
+		}
+		return result
+	})
+}
+
+// sortByRelevance orders the laptops from the highest to the lowest score
+func sortByRelevance(laptops []*pb.Laptop, scores map[string]float64) {
+	slices.SortFunc(laptops, func(a *pb.Laptop, b *pb.Laptop) int {
+		if result := cmp.Compare(scores[b.GetId()], scores[a.GetId()]); result != 0 {
+			return result
+		}
+		return cmp.Compare(a.GetId(), b.GetId())
+	})
+}
@@ -1,49 +1,130 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"sync"
 
-	"github.com/jinzhu/copier"
 	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/query"
+	"github.com/pokala15/pcbook/units"
+	"github.com/pokala15/pcbook/validation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var ErrAlreadyExists = errors.New("record already exists")
+var (
+	ErrAlreadyExists    = errors.New("record already exists")
+	ErrNotFound         = errors.New("record not found")
+	ErrInvalidFieldMask = errors.New("invalid field mask")
+	ErrVersionMismatch  = errors.New("record version mismatch")
+)
 
 type LaptopStore interface {
+	// Save fails with ErrAlreadyExists if a laptop with the same id is stored
 	Save(laptop *pb.Laptop) error
+	// FindById fails with ErrNotFound if no laptop has the id
 	FindById(id string) (*pb.Laptop, error)
-	Search(filter *pb.Filter, ctx context.Context, found func(laptop *pb.Laptop) error) error
+	// Update and Delete fail with ErrVersionMismatch when expectedVersion is
+	// non-zero and doesn't match the version of the stored laptop.
+	// Update fails with a *validation.Error if the updated laptop is not valid.
+	Update(laptop *pb.Laptop, mask *fieldmaskpb.FieldMask, expectedVersion uint64) (*pb.Laptop, error)
+	Delete(id string, expectedVersion uint64) error
+	// List returns at most limit laptops ordered by id, starting after the given id
+	List(afterId string, limit int) ([]*pb.Laptop, error)
+	// Search calls found for every matching laptop until found returns an error,
+	// which is then returned by Search
+	Search(filter *pb.Filter, options SearchOptions, ctx context.Context, found func(laptop *pb.Laptop) error) error
+}
+
+// SearchOptions controls the order and the number of laptops found by a search
+type SearchOptions struct {
+	Query      query.Predicate // laptops must also match the query if set
+	Text       string          // free text matched against laptop, CPU and GPU names
+	SortBy     *pb.Sort        // results matching a text are ordered by relevance if unset
+	MaxResults int             // zero means no limit
 }
 
 type InMemoryLaptopStore struct {
 	mutex sync.RWMutex
-	data  map[string]*pb.Laptop
+	data  map[string]*pb.Laptop // laptops are replaced on update, never modified in place
+	ids   *orderedSet[string]   // ids of data in order, used for paging
+	text  *textIndex
+
+	// secondary indexes of the filter criteria, so that searches don't scan every laptop
+	priceIndex *sortedIndex[float64]
+	coresIndex *sortedIndex[uint32]
+	ghzIndex   *sortedIndex[float64]
+	ramIndex   *sortedIndex[uint64]
 }
 
 func NewInMemoryLaptopStore() *InMemoryLaptopStore {
 	return &InMemoryLaptopStore{
 		data: make(map[string]*pb.Laptop),
+		ids:  newOrderedSet(cmp.Compare[string]),
+		text: newTextIndex(),
+		priceIndex: newSortedIndex(func(laptop *pb.Laptop) float64 {
+			return laptop.GetPriceUsd()
+		}),
+		coresIndex: newSortedIndex(func(laptop *pb.Laptop) uint32 {
+			return laptop.GetCpu().GetNumberCores()
+		}),
+		ghzIndex: newSortedIndex(func(laptop *pb.Laptop) float64 {
+			return laptop.GetCpu().GetMinGhz()
+		}),
+		ramIndex: newSortedIndex(func(laptop *pb.Laptop) uint64 {
+			return units.SaturatedBits(laptop.GetRam())
+		}),
 	}
 }
 
+func (store *InMemoryLaptopStore) addToIndexes(laptop *pb.Laptop) {
+	store.text.add(laptop)
+	store.priceIndex.add(laptop)
+	store.coresIndex.add(laptop)
+	store.ghzIndex.add(laptop)
+	store.ramIndex.add(laptop)
+}
+
+func (store *InMemoryLaptopStore) removeFromIndexes(laptop *pb.Laptop) {
+	store.text.remove(laptop.GetId())
+	store.priceIndex.remove(laptop)
+	store.coresIndex.remove(laptop)
+	store.ghzIndex.remove(laptop)
+	store.ramIndex.remove(laptop)
+}
+
 func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
+
+	saved, err := store.toSave(laptop)
+	if err != nil {
+		return err
+	}
+	store.put(saved)
+	return nil
+}
+
+// toSave returns the laptop as Save would store it, or the error Save would return.
+// The caller must hold the lock.
+func (store *InMemoryLaptopStore) toSave(laptop *pb.Laptop) (*pb.Laptop, error) {
 	if store.data[laptop.Id] != nil {
-		return ErrAlreadyExists
+		return nil, ErrAlreadyExists
 	}
 
 	other, err := createDeepCopy(laptop)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	store.data[other.Id] = other
-	return nil
+	other.Version = 1
+	return other, nil
 }
 
 func (store *InMemoryLaptopStore) FindById(id string) (*pb.Laptop, error) {
@@ -51,7 +132,7 @@ func (store *InMemoryLaptopStore) FindById(id string) (*pb.Laptop, error) {
 	defer store.mutex.RUnlock()
 
 	if val, ok := store.data[id]; !ok {
-		return nil, fmt.Errorf("id not found")
+		return nil, ErrNotFound
 	} else {
 		other, err := createDeepCopy(val)
 		if err != nil {
@@ -61,70 +142,253 @@ func (store *InMemoryLaptopStore) FindById(id string) (*pb.Laptop, error) {
 	}
 }
 
-func createDeepCopy(val *pb.Laptop) (*pb.Laptop, error) {
-	other := &pb.Laptop{}
-	if err := copier.Copy(other, val); err != nil {
-		return nil, fmt.Errorf("can't copy the value: %s", err)
+// Update applies the fields of laptop selected by the mask to the stored laptop
+// with the same id and returns the updated laptop.
+func (store *InMemoryLaptopStore) Update(laptop *pb.Laptop,
+	mask *fieldmaskpb.FieldMask,
+	expectedVersion uint64,
+) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	updated, err := store.toUpdate(laptop, mask, expectedVersion)
+	if err != nil {
+		return nil, err
 	}
-	return other, nil
+	store.put(updated)
+
+	return createDeepCopy(updated)
 }
 
-func (store *InMemoryLaptopStore) Search(filter *pb.Filter,
-	ctx context.Context,
-	found func(laptop *pb.Laptop) error,
-) error {
+// toUpdate returns the laptop as Update would store it, or the error Update would return.
+// The caller must hold the lock.
+func (store *InMemoryLaptopStore) toUpdate(laptop *pb.Laptop,
+	mask *fieldmaskpb.FieldMask,
+	expectedVersion uint64,
+) (*pb.Laptop, error) {
+	val, ok := store.data[laptop.GetId()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(val, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	// stored laptops are replaced rather than modified in place
+	updated := proto.Clone(val).(*pb.Laptop)
+	if err := applyFieldMask(updated, proto.Clone(laptop), mask); err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateLaptop(updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = timestamppb.Now()
+	updated.Version++
+	return updated, nil
+}
+
+func (store *InMemoryLaptopStore) Delete(id string, expectedVersion uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.checkDelete(id, expectedVersion); err != nil {
+		return err
+	}
+	store.remove(id)
+	return nil
+}
+
+// checkDelete returns the error Delete would return, if any. The caller must hold the lock.
+func (store *InMemoryLaptopStore) checkDelete(id string, expectedVersion uint64) error {
+	val, ok := store.data[id]
+	if !ok {
+		return ErrNotFound
+	}
+	return checkVersion(val, expectedVersion)
+}
+
+// put stores the laptop as it is, replacing the laptop with the same id.
+// The caller must hold the write lock.
+func (store *InMemoryLaptopStore) put(laptop *pb.Laptop) {
+	if old, ok := store.data[laptop.Id]; ok {
+		store.removeFromIndexes(old)
+	} else {
+		store.ids.add(laptop.Id)
+	}
+	store.data[laptop.Id] = laptop
+	store.addToIndexes(laptop)
+}
+
+// remove deletes the laptop with the id if it exists.
+// The caller must hold the write lock.
+func (store *InMemoryLaptopStore) remove(id string) {
+	old, ok := store.data[id]
+	if !ok {
+		return
+	}
+	delete(store.data, id)
+	store.removeFromIndexes(old)
+	store.ids.remove(id)
+}
+
+// restore stores the laptop without any check, to replay or roll back changes
+func (store *InMemoryLaptopStore) restore(laptop *pb.Laptop) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.put(laptop)
+}
+
+// discard deletes the laptop without any check, to replay or roll back changes
+func (store *InMemoryLaptopStore) discard(id string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.remove(id)
+}
+
+// snapshot returns every stored laptop ordered by id. The laptops must not be modified.
+func (store *InMemoryLaptopStore) snapshot() []*pb.Laptop {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
-	for _, v := range store.data {
-		// time.Sleep(time.Second)
-		if ctx.Err() == context.DeadlineExceeded || ctx.Err() == context.Canceled {
-			log.Println("context is cancelled")
-			return fmt.Errorf("context is cancelled")
+	laptops := make([]*pb.Laptop, 0, store.ids.len())
+	for id := range store.ids.from(0) {
+		laptops = append(laptops, store.data[id])
+	}
+	return laptops
+}
+
+func (store *InMemoryLaptopStore) List(afterId string, limit int) ([]*pb.Laptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	i := store.ids.rank(func(id string) bool {
+		return id <= afterId
+	})
+
+	laptops := make([]*pb.Laptop, 0, min(limit, store.ids.len()-i))
+	for id := range store.ids.from(i) {
+		if len(laptops) == limit {
+			break
 		}
-		if isQualifiedLaptop(v, filter) {
-			other, err := createDeepCopy(v)
-			if err != nil {
-				return err
-			}
-			found(other)
+		other, err := createDeepCopy(store.data[id])
+		if err != nil {
+			return nil, err
 		}
+		laptops = append(laptops, other)
+	}
+	return laptops, nil
+}
+
+func checkVersion(laptop *pb.Laptop, expectedVersion uint64) error {
+	if expectedVersion != 0 && laptop.Version != expectedVersion {
+		return fmt.Errorf("%w: expected %d, found %d", ErrVersionMismatch, expectedVersion, laptop.Version)
 	}
 	return nil
 }
 
-func isQualifiedLaptop(laptop *pb.Laptop, filter *pb.Filter) bool {
-	if laptop.PriceUsd > filter.MaxPriceUsd {
-		return false
+// candidates returns the ids of the laptops that may match the filter and the text scores,
+// taken from the most selective index. It returns false if no index beats a full scan.
+func (store *InMemoryLaptopStore) candidates(filter *pb.Filter, scores map[string]float64) ([]string, bool) {
+	best := len(store.data)
+	var selected func() []string
+	consider := func(count int, ids func() []string) {
+		if count < best {
+			best = count
+			selected = ids
+		}
+	}
+
+	if scores != nil {
+		consider(len(scores), func() []string {
+			return slices.Collect(maps.Keys(scores))
+		})
+	}
+	if filter != nil {
+		consider(store.priceIndex.atMost(filter.GetMaxPriceUsd()))
+		consider(store.coresIndex.atLeast(filter.GetMinCpuCores()))
+		consider(store.ghzIndex.atLeast(filter.GetMinCpuGhz()))
+		consider(store.ramIndex.atLeast(units.SaturatedBits(filter.GetMinRam())))
+	}
+
+	if selected == nil {
+		return nil, false
+	}
+	return selected(), true
+}
+
+// findMatches returns the stored laptops matching the filter and the options, in the order of the options.
+// Stored laptops are never modified in place, so the result stays consistent after the lock is released.
+func (store *InMemoryLaptopStore) findMatches(filter *pb.Filter, options SearchOptions) []*pb.Laptop {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	scores := store.text.search(options.Text)
+	matches := []*pb.Laptop{}
+	match := func(v *pb.Laptop) {
+		if scores != nil {
+			if _, ok := scores[v.Id]; !ok {
+				return
+			}
+		}
+		if isQualifiedLaptop(v, filter) && (options.Query == nil || options.Query(v)) {
+			matches = append(matches, v)
+		}
 	}
-	if laptop.Cpu.NumberCores < filter.MinCpuCores {
-		return false
+
+	if ids, ok := store.candidates(filter, scores); ok {
+		for _, id := range ids {
+			match(store.data[id])
+		}
+	} else {
+		for _, v := range store.data {
+			match(v)
+		}
 	}
-	if laptop.Cpu.MinGhz < filter.MinCpuGhz {
-		return false
+
+	if scores != nil && options.SortBy.GetField() == pb.Sort_UNKNOWN {
+		sortByRelevance(matches, scores)
+	} else {
+		sortLaptops(matches, options.SortBy)
 	}
-	if toBit(laptop.Ram) < toBit(filter.MinRam) {
-		return false
+	if options.MaxResults > 0 && len(matches) > options.MaxResults {
+		matches = matches[:options.MaxResults]
+	}
+	return matches
+}
+
+func createDeepCopy(val *pb.Laptop) (*pb.Laptop, error) {
+	other, ok := proto.Clone(val).(*pb.Laptop)
+	if !ok {
+		return nil, fmt.Errorf("can't copy the value: %v", val)
 	}
-	return true
+	return other, nil
 }
 
-func toBit(memory *pb.Memory) uint64 {
-	value := memory.Value
-	switch memory.Unit {
-	case pb.Memory_BIT:
-		return value
-	case pb.Memory_BYTE:
-		return value << 3 // 2^3
-	case pb.Memory_KILOBYTE:
-		return value << 13 // 8 * 10^3 == 2^3 * 2^10
-	case pb.Memory_MEGABYTE:
-		return value << 23
-	case pb.Memory_GIGABYTE:
-		return value << 33
-	case pb.Memory_TERABYTE:
-		return value << 43
-	default:
-		return 0
+func (store *InMemoryLaptopStore) Search(filter *pb.Filter,
+	options SearchOptions,
+	ctx context.Context,
+	found func(laptop *pb.Laptop) error,
+) error {
+	// found may block on slow clients, so it is called on a snapshot of the
+	// matches rather than while holding the lock that writers wait for
+	matches := store.findMatches(filter, options)
+
+	for _, v := range matches {
+		// time.Sleep(time.Second)
+		if ctx.Err() == context.DeadlineExceeded || ctx.Err() == context.Canceled {
+			log.Println("context is cancelled")
+			return ctx.Err()
+		}
+		other, err := createDeepCopy(v)
+		if err != nil {
+			return err
+		}
+		// stop at the first failure, e.g. when the client is gone
+		if err := found(other); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -0,0 +1,65 @@
+package service_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/pokala15/pcbook/service"
+	"github.com/pokala15/pcbook/service/storetest"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestInMemoryLaptopStoreSuite(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunLaptopStoreSuite(t, func(t *testing.T) service.LaptopStore {
+		return service.NewInMemoryLaptopStore()
+	})
+}
+
+func TestFileLaptopStoreSuite(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunLaptopStoreSuite(t, func(t *testing.T) service.LaptopStore {
+		store, err := service.NewFileLaptopStore(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestSQLLaptopStoreSuite(t *testing.T) {
+	t.Parallel()
+
+	storetest.RunLaptopStoreSuite(t, func(t *testing.T) service.LaptopStore {
+		// an in-memory database lives as long as its connection, so the store gets a single one
+		db, err := sql.Open("sqlite", ":memory:")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		store, err := service.NewSQLLaptopStore(db)
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func TestSQLLaptopStoreFileSuite(t *testing.T) {
+	t.Parallel()
+
+	// a database file is shared by several connections, whose writes wait for each other
+	storetest.RunLaptopStoreSuite(t, func(t *testing.T) service.LaptopStore {
+		path := filepath.Join(t.TempDir(), "laptops.db")
+		db, err := sql.Open("sqlite", fmt.Sprintf(
+			"file:%s?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)", path))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		store, err := service.NewSQLLaptopStore(db)
+		require.NoError(t, err)
+		return store
+	})
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryLaptopStoreSearchIndexes(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < 200; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	// updates and deletes must keep the indexes in sync with the data
+	laptop := sample.NewLaptop()
+	err := store.Save(laptop)
+	require.NoError(t, err)
+	laptop.PriceUsd = 100
+	_, err = store.Update(laptop, nil, 0)
+	require.NoError(t, err)
+	err = store.Delete(sample.NewLaptop().Id, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	filters := []*pb.Filter{
+		{MaxPriceUsd: 1600},
+		{MaxPriceUsd: 100},
+		{MaxPriceUsd: 5000, MinCpuCores: 7},
+		{MaxPriceUsd: 5000, MinCpuGhz: 2.9},
+		{MaxPriceUsd: 5000, MinRam: &pb.Memory{Value: 60, Unit: pb.Memory_GIGABYTE}},
+		{MaxPriceUsd: 3000, MinCpuCores: 4, MinCpuGhz: 2.5, MinRam: &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}},
+	}
+	for _, filter := range filters {
+		expected := make(map[string]bool)
+		for id, v := range store.data {
+			if isQualifiedLaptop(v, filter) {
+				expected[id] = true
+			}
+		}
+
+		found := make(map[string]bool)
+		err := store.Search(filter, SearchOptions{}, context.Background(), func(laptop *pb.Laptop) error {
+			found[laptop.Id] = true
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, found, "filter: %v", filter)
+	}
+
+	ids, ok := store.candidates(&pb.Filter{MaxPriceUsd: 100}, nil)
+	require.True(t, ok)
+	require.Equal(t, []string{laptop.Id}, ids)
+}
+
+func BenchmarkInMemoryLaptopStoreSave(b *testing.B) {
+	for _, size := range []int{10_000, 100_000} {
+		laptops := make([]*pb.Laptop, size)
+		for i := range laptops {
+			laptops[i] = sample.NewLaptop()
+		}
+
+		b.Run(fmt.Sprintf("load_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store := NewInMemoryLaptopStore()
+				for _, laptop := range laptops {
+					err := store.Save(laptop)
+					require.NoError(b, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInMemoryLaptopStoreSearch(b *testing.B) {
+	for _, size := range []int{10_000, 100_000} {
+		store := NewInMemoryLaptopStore()
+		for i := 0; i < size; i++ {
+			err := store.Save(sample.NewLaptop())
+			require.NoError(b, err)
+		}
+		// sample prices are spread between 1500 and 3500, so about 1% of the laptops match
+		filter := &pb.Filter{
+			MaxPriceUsd: 1520,
+			MinRam:      &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE},
+		}
+		found := func(laptop *pb.Laptop) error {
+			return nil
+		}
+
+		b.Run(fmt.Sprintf("index_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				err := store.Search(filter, SearchOptions{}, context.Background(), found)
+				require.NoError(b, err)
+			}
+		})
+
+		// the full scan that Search did before the indexes
+		b.Run(fmt.Sprintf("scan_%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store.mutex.RLock()
+				for _, v := range store.data {
+					if isQualifiedLaptop(v, filter) {
+						other, err := createDeepCopy(v)
+						require.NoError(b, err)
+						found(other)
+					}
+				}
+				store.mutex.RUnlock()
+			}
+		})
+	}
+}
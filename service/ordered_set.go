@@ -0,0 +1,153 @@
+package service
+
+import (
+	"iter"
+	"math/rand/v2"
+)
+
+// orderedSet keeps distinct values in order. It is a treap whose nodes count the values
+// below them, so that values are added and removed, and positions are found, in O(log n).
+type orderedSet[T any] struct {
+	compare func(a T, b T) int
+	root    *treapNode[T]
+}
+
+type treapNode[T any] struct {
+	value       T
+	priority    uint32 // a node has a higher priority than its children
+	size        int    // number of values in the subtree of the node
+	left, right *treapNode[T]
+}
+
+func newOrderedSet[T any](compare func(a T, b T) int) *orderedSet[T] {
+	return &orderedSet[T]{compare: compare}
+}
+
+func (node *treapNode[T]) count() int {
+	if node == nil {
+		return 0
+	}
+	return node.size
+}
+
+func (node *treapNode[T]) update() *treapNode[T] {
+	node.size = node.left.count() + 1 + node.right.count()
+	return node
+}
+
+// splitTreap divides the subtree into the values for which before returns true and the others.
+// before must return true for every value up to some position and false after it.
+func splitTreap[T any](node *treapNode[T], before func(value T) bool) (*treapNode[T], *treapNode[T]) {
+	if node == nil {
+		return nil, nil
+	}
+	if before(node.value) {
+		left, right := splitTreap(node.right, before)
+		node.right = left
+		return node.update(), right
+	}
+	left, right := splitTreap(node.left, before)
+	node.left = right
+	return left, node.update()
+}
+
+// mergeTreap joins two subtrees, every value of left being lower than every value of right
+func mergeTreap[T any](left *treapNode[T], right *treapNode[T]) *treapNode[T] {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		left.right = mergeTreap(left.right, right)
+		return left.update()
+	default:
+		right.left = mergeTreap(left, right.left)
+		return right.update()
+	}
+}
+
+func (set *orderedSet[T]) len() int {
+	return set.root.count()
+}
+
+func (set *orderedSet[T]) contains(value T) bool {
+	for node := set.root; node != nil; {
+		switch result := set.compare(value, node.value); {
+		case result < 0:
+			node = node.left
+		case result > 0:
+			node = node.right
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts the value and reports whether it wasn't in the set already
+func (set *orderedSet[T]) add(value T) bool {
+	if set.contains(value) {
+		return false
+	}
+	left, right := splitTreap(set.root, func(other T) bool {
+		return set.compare(other, value) < 0
+	})
+	node := &treapNode[T]{value: value, priority: rand.Uint32(), size: 1}
+	set.root = mergeTreap(mergeTreap(left, node), right)
+	return true
+}
+
+// remove deletes the value and reports whether it was in the set
+func (set *orderedSet[T]) remove(value T) bool {
+	left, rest := splitTreap(set.root, func(other T) bool {
+		return set.compare(other, value) < 0
+	})
+	found, right := splitTreap(rest, func(other T) bool {
+		return set.compare(other, value) <= 0
+	})
+	set.root = mergeTreap(left, right)
+	return found != nil
+}
+
+// rank returns the number of values for which before returns true.
+// before must return true for every value up to some position and false after it.
+func (set *orderedSet[T]) rank(before func(value T) bool) int {
+	rank := 0
+	for node := set.root; node != nil; {
+		if before(node.value) {
+			rank += node.left.count() + 1
+			node = node.right
+		} else {
+			node = node.left
+		}
+	}
+	return rank
+}
+
+// from returns the values in order, starting at the position i
+func (set *orderedSet[T]) from(i int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		var stack []*treapNode[T]
+		for node := set.root; node != nil; {
+			if i <= node.left.count() {
+				stack = append(stack, node)
+				node = node.left
+			} else {
+				i -= node.left.count() + 1
+				node = node.right
+			}
+		}
+
+		for len(stack) > 0 {
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(node.value) {
+				return
+			}
+			for node = node.right; node != nil; node = node.left {
+				stack = append(stack, node)
+			}
+		}
+	}
+}
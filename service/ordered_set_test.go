@@ -0,0 +1,43 @@
+package service
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedSet(t *testing.T) {
+	t.Parallel()
+
+	set := newOrderedSet(cmp.Compare[int])
+	var expected []int
+	for i := 0; i < 2000; i++ {
+		value := rand.Intn(500)
+		j, found := slices.BinarySearch(expected, value)
+		if rand.Intn(3) == 0 {
+			require.Equal(t, found, set.remove(value))
+			if found {
+				expected = slices.Delete(expected, j, j+1)
+			}
+		} else {
+			require.Equal(t, !found, set.add(value))
+			if !found {
+				expected = slices.Insert(expected, j, value)
+			}
+		}
+	}
+
+	require.Equal(t, len(expected), set.len())
+	require.Equal(t, expected, slices.Collect(set.from(0)))
+	for _, value := range []int{-1, 0, 250, 499, 500} {
+		i, _ := slices.BinarySearch(expected, value)
+		require.Equal(t, i, set.rank(func(other int) bool {
+			return other < value
+		}))
+		require.Equal(t, expected[i:], slices.AppendSeq([]int{}, set.from(i)))
+	}
+	require.Empty(t, slices.Collect(set.from(set.len())))
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// encodePageToken returns an opaque token pointing after the laptop with the given id
+func encodePageToken(lastId string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastId))
+}
+
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("malformed page token: %v", err)
+	}
+	// ids are stored in their canonical form, which another form of the same uuid would not match
+	lastId := string(data)
+	id, err := uuid.Parse(lastId)
+	if err != nil {
+		return "", fmt.Errorf("malformed page token: %v", err)
+	}
+	if id.String() != lastId {
+		return "", fmt.Errorf("malformed page token: %q is not a canonical uuid", lastId)
+	}
+	return lastId, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"cmp"
+	"iter"
+
+	"github.com/pokala15/pcbook/pb"
+)
+
+type indexEntry[K cmp.Ordered] struct {
+	key K
+	id  string
+}
+
+// sortedIndex keeps laptop ids ordered by a key of the laptop,
+// so that the laptops within a range of keys are found without a full scan
+type sortedIndex[K cmp.Ordered] struct {
+	key     func(laptop *pb.Laptop) K
+	entries *orderedSet[indexEntry[K]] // ordered by key, then by id
+}
+
+func newSortedIndex[K cmp.Ordered](key func(laptop *pb.Laptop) K) *sortedIndex[K] {
+	return &sortedIndex[K]{
+		key: key,
+		entries: newOrderedSet(func(a indexEntry[K], b indexEntry[K]) int {
+			if result := cmp.Compare(a.key, b.key); result != 0 {
+				return result
+			}
+			return cmp.Compare(a.id, b.id)
+		}),
+	}
+}
+
+func (index *sortedIndex[K]) add(laptop *pb.Laptop) {
+	index.entries.add(indexEntry[K]{key: index.key(laptop), id: laptop.GetId()})
+}
+
+// remove deletes the entry of the laptop as it was when it was added
+func (index *sortedIndex[K]) remove(laptop *pb.Laptop) {
+	index.entries.remove(indexEntry[K]{key: index.key(laptop), id: laptop.GetId()})
+}
+
+// atLeast returns the number of entries with a key greater than or equal to min,
+// and a function collecting their ids
+func (index *sortedIndex[K]) atLeast(min K) (int, func() []string) {
+	i := index.entries.rank(func(entry indexEntry[K]) bool {
+		return entry.key < min
+	})
+	count := index.entries.len() - i
+	return count, func() []string {
+		return entryIds(index.entries.from(i), count)
+	}
+}
+
+// atMost returns the number of entries with a key lower than or equal to max,
+// and a function collecting their ids
+func (index *sortedIndex[K]) atMost(max K) (int, func() []string) {
+	count := index.entries.rank(func(entry indexEntry[K]) bool {
+		return entry.key <= max
+	})
+	return count, func() []string {
+		return entryIds(index.entries.from(0), count)
+	}
+}
+
+// entryIds returns the ids of the first count entries
+func entryIds[K cmp.Ordered](entries iter.Seq[indexEntry[K]], count int) []string {
+	ids := make([]string, 0, count)
+	for entry := range entries {
+		if len(ids) == count {
+			break
+		}
+		ids = append(ids, entry.id)
+	}
+	return ids
+}
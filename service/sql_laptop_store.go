@@ -0,0 +1,741 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+	"github.com/pokala15/pcbook/validation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxSqlParameters bounds the ids of an IN list, below the limit of every SQLite build
+const maxSqlParameters = 500
+
+// sqlMigrations are applied in order on startup, each one at most once.
+// The version of a migration is its index plus one, new migrations are only ever appended.
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE laptops (
+			id TEXT PRIMARY KEY,
+			brand TEXT NOT NULL,
+			name TEXT NOT NULL,
+			ram_value INTEGER,
+			ram_unit INTEGER,
+			ram_bits INTEGER NOT NULL,
+			weight_kg REAL,
+			weight_lb REAL,
+			price_usd REAL NOT NULL,
+			release_year INTEGER NOT NULL,
+			updated_at INTEGER,
+			version INTEGER NOT NULL
+		)`,
+		`CREATE TABLE cpus (
+			laptop_id TEXT PRIMARY KEY REFERENCES laptops (id),
+			brand TEXT NOT NULL,
+			name TEXT NOT NULL,
+			number_cores INTEGER NOT NULL,
+			number_threads INTEGER NOT NULL,
+			min_ghz REAL NOT NULL,
+			max_ghz REAL NOT NULL
+		)`,
+		`CREATE TABLE gpus (
+			laptop_id TEXT NOT NULL REFERENCES laptops (id),
+			position INTEGER NOT NULL,
+			brand TEXT NOT NULL,
+			name TEXT NOT NULL,
+			min_ghz REAL NOT NULL,
+			max_ghz REAL NOT NULL,
+			memory_value INTEGER,
+			memory_unit INTEGER,
+			memory_bits INTEGER NOT NULL,
+			PRIMARY KEY (laptop_id, position)
+		)`,
+		`CREATE TABLE storages (
+			laptop_id TEXT NOT NULL REFERENCES laptops (id),
+			position INTEGER NOT NULL,
+			driver INTEGER NOT NULL,
+			memory_value INTEGER,
+			memory_unit INTEGER,
+			memory_bits INTEGER NOT NULL,
+			PRIMARY KEY (laptop_id, position)
+		)`,
+		`CREATE TABLE screens (
+			laptop_id TEXT PRIMARY KEY REFERENCES laptops (id),
+			size_inch REAL NOT NULL,
+			width INTEGER,
+			height INTEGER,
+			panel INTEGER NOT NULL,
+			multitouch INTEGER NOT NULL
+		)`,
+		`CREATE TABLE keyboards (
+			laptop_id TEXT PRIMARY KEY REFERENCES laptops (id),
+			layout INTEGER NOT NULL,
+			backlit INTEGER NOT NULL
+		)`,
+		`CREATE INDEX laptops_price_usd ON laptops (price_usd)`,
+		`CREATE INDEX laptops_ram_bits ON laptops (ram_bits)`,
+		`CREATE INDEX cpus_number_cores ON cpus (number_cores)`,
+	},
+}
+
+// columns and tables of a laptop row, the sub-messages without a row are nil
+const (
+	laptopColumns = `l.id, l.brand, l.name, l.ram_value, l.ram_unit, l.weight_kg, l.weight_lb,
+		l.price_usd, l.release_year, l.updated_at, l.version,
+		c.laptop_id, c.brand, c.name, c.number_cores, c.number_threads, c.min_ghz, c.max_ghz,
+		s.laptop_id, s.size_inch, s.width, s.height, s.panel, s.multitouch,
+		k.laptop_id, k.layout, k.backlit`
+	laptopTables = `laptops l
+		LEFT JOIN cpus c ON c.laptop_id = l.id
+		LEFT JOIN screens s ON s.laptop_id = l.id
+		LEFT JOIN keyboards k ON k.laptop_id = l.id`
+)
+
+// sqlQuerier is implemented by both *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// SQLLaptopStore is a LaptopStore kept in a SQL database, with a table per sub-message
+// of a laptop. Queries are written for SQLite, whose driver must be registered by the caller.
+// A database shared by several connections needs immediate transactions and a busy timeout, so that
+// writes wait for each other, such as modernc.org/sqlite with `_txlock=immediate&_pragma=busy_timeout(10000)`.
+// Search translates the filter into a WHERE clause; queries and free text are matched in Go.
+type SQLLaptopStore struct {
+	db *sql.DB
+}
+
+// NewSQLLaptopStore returns a store using the database, after migrating its schema
+func NewSQLLaptopStore(db *sql.DB) (*SQLLaptopStore, error) {
+	store := &SQLLaptopStore{db: db}
+	if err := store.migrate(context.Background()); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *SQLLaptopStore) migrate(ctx context.Context) error {
+	_, err := store.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("can't create schema migrations table: %v", err)
+	}
+
+	var current int
+	err = store.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("can't read schema version: %v", err)
+	}
+
+	for version := current + 1; version <= len(sqlMigrations); version++ {
+		err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+			for _, statement := range sqlMigrations[version-1] {
+				if _, err := tx.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("can't migrate schema to version %d: %v", version, err)
+		}
+	}
+	return nil
+}
+
+// inTransaction runs f in a transaction that is committed if f succeeds and rolled back otherwise
+func (store *SQLLaptopStore) inTransaction(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *SQLLaptopStore) Save(laptop *pb.Laptop) error {
+	ctx := context.Background()
+	return store.inTransaction(ctx, func(tx *sql.Tx) error {
+		other := proto.Clone(laptop).(*pb.Laptop)
+		other.Version = 1
+		return insertLaptop(ctx, tx, other)
+	})
+}
+
+func (store *SQLLaptopStore) FindById(id string) (*pb.Laptop, error) {
+	return findLaptop(context.Background(), store.db, id)
+}
+
+func (store *SQLLaptopStore) Update(laptop *pb.Laptop,
+	mask *fieldmaskpb.FieldMask,
+	expectedVersion uint64,
+) (*pb.Laptop, error) {
+	ctx := context.Background()
+	var updated *pb.Laptop
+	err := store.inTransaction(ctx, func(tx *sql.Tx) error {
+		current, err := findLaptop(ctx, tx, laptop.GetId())
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, expectedVersion); err != nil {
+			return err
+		}
+
+		updated = proto.Clone(current).(*pb.Laptop)
+		if err := applyFieldMask(updated, proto.Clone(laptop), mask); err != nil {
+			return err
+		}
+		if err := validation.ValidateLaptop(updated); err != nil {
+			return err
+		}
+		updated.UpdatedAt = timestamppb.Now()
+		updated.Version++
+
+		// the laptop is replaced, like in the other stores
+		if err := deleteLaptop(ctx, tx, current); err != nil {
+			return err
+		}
+		return insertLaptop(ctx, tx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (store *SQLLaptopStore) Delete(id string, expectedVersion uint64) error {
+	ctx := context.Background()
+	return store.inTransaction(ctx, func(tx *sql.Tx) error {
+		current, err := findLaptop(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(current, expectedVersion); err != nil {
+			return err
+		}
+		return deleteLaptop(ctx, tx, current)
+	})
+}
+
+func (store *SQLLaptopStore) List(afterId string, limit int) ([]*pb.Laptop, error) {
+	return selectLaptops(context.Background(), store.db,
+		`WHERE l.id > ? ORDER BY l.id LIMIT ?`, afterId, limit)
+}
+
+// Search always selects the laptops matching the filter in the database, which also sorts them
+// unless they are ranked by relevance to the text. Laptops are only loaded into Go to be matched
+// against the query and the text, which SQL can't express, and are then limited there.
+func (store *SQLLaptopStore) Search(filter *pb.Filter,
+	options SearchOptions,
+	ctx context.Context,
+	found func(laptop *pb.Laptop) error,
+) error {
+	// report cancellation as it is rather than as a failed query
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	where := filterToWhere(filter)
+	clause, args := where.clause(), where.args
+
+	// laptops matching a text are ranked by relevance unless they are sorted,
+	// and only the laptops matching the query and the text count towards the limit
+	hasText := len(tokenize(options.Text)) > 0
+	sorted := !hasText || options.SortBy.GetField() != pb.Sort_UNKNOWN
+	inDatabase := options.Query == nil && !hasText
+	if sorted {
+		clause += " ORDER BY " + sortOrder(options.SortBy)
+	}
+	if inDatabase && options.MaxResults > 0 {
+		clause += " LIMIT ?"
+		args = append(args, options.MaxResults)
+	}
+
+	// found may block on slow clients, so it is called once the rows are closed
+	matches, err := selectLaptops(ctx, store.db, clause, args...)
+	if err != nil {
+		return err
+	}
+	if !inDatabase {
+		matches = matchInGo(matches, options, sorted)
+	}
+
+	for _, laptop := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := found(laptop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchInGo keeps the laptops matching the query and the text of the options, ranks them
+// by relevance unless they are sorted already, and limits them like the in-memory store does
+func matchInGo(laptops []*pb.Laptop, options SearchOptions, sorted bool) []*pb.Laptop {
+	text := newTextIndex()
+	for _, laptop := range laptops {
+		text.add(laptop)
+	}
+	scores := text.search(options.Text)
+
+	matches := []*pb.Laptop{}
+	for _, laptop := range laptops {
+		if scores != nil {
+			if _, ok := scores[laptop.Id]; !ok {
+				continue
+			}
+		}
+		if options.Query == nil || options.Query(laptop) {
+			matches = append(matches, laptop)
+		}
+	}
+
+	if !sorted {
+		sortByRelevance(matches, scores)
+	}
+	if options.MaxResults > 0 && len(matches) > options.MaxResults {
+		matches = matches[:options.MaxResults]
+	}
+	return matches
+}
+
+// sqlWhere collects the conditions of a WHERE clause and their arguments
+type sqlWhere struct {
+	conditions []string
+	args       []any
+}
+
+func (where *sqlWhere) add(condition string, args ...any) {
+	where.conditions = append(where.conditions, condition)
+	where.args = append(where.args, args...)
+}
+
+// addIn adds a condition that the expression is one of the values, if there are any
+func addIn[T any](where *sqlWhere, expression string, values []T) {
+	if len(values) == 0 {
+		return
+	}
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	where.add(fmt.Sprintf("%s IN (%s)", expression, placeholders(len(values))), args...)
+}
+
+func (where *sqlWhere) clause() string {
+	if len(where.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(where.conditions, " AND ")
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+func lowerAll(values []string) []string {
+	lower := make([]string, len(values))
+	for i, value := range values {
+		lower[i] = strings.ToLower(value)
+	}
+	return lower
+}
+
+func sqlWeightKg() string {
+	return fmt.Sprintf("COALESCE(l.weight_kg, l.weight_lb * %v, 0)", units.KilogramsPerPound)
+}
+
+// filterToWhere translates the filter into the conditions checked by isQualifiedLaptop.
+// Names are compared with LOWER, which SQLite only applies to ASCII letters.
+func filterToWhere(filter *pb.Filter) *sqlWhere {
+	where := &sqlWhere{}
+	if filter == nil {
+		return where
+	}
+
+	where.add("l.price_usd <= ?", filter.GetMaxPriceUsd())
+	where.add("COALESCE(c.number_cores, 0) >= ?", filter.GetMinCpuCores())
+	where.add("COALESCE(c.min_ghz, 0) >= ?", filter.GetMinCpuGhz())
+	where.add("l.ram_bits >= ?", sqlMemoryBits(filter.GetMinRam()))
+	addIn(where, "LOWER(l.brand)", lowerAll(filter.GetBrands()))
+	addIn(where, "LOWER(l.name)", lowerAll(filter.GetNames()))
+
+	if filter.GetMinGpuMemory() != nil || len(filter.GetGpuBrands()) > 0 {
+		gpu := &sqlWhere{}
+		gpu.add("g.laptop_id = l.id")
+		gpu.add("g.memory_bits >= ?", sqlMemoryBits(filter.GetMinGpuMemory()))
+		addIn(gpu, "LOWER(g.brand)", lowerAll(filter.GetGpuBrands()))
+		where.add("EXISTS (SELECT 1 FROM gpus g "+gpu.clause()+")", gpu.args...)
+	}
+	addStorageCapacity(where, pb.Storage_SSD, filter.GetMinSsdCapacity())
+	addStorageCapacity(where, pb.Storage_HDD, filter.GetMinHddCapacity())
+
+	if filter.GetMinScreenSizeInch() > 0 {
+		where.add("COALESCE(s.size_inch, 0) >= ?", float64(filter.GetMinScreenSizeInch()))
+	}
+	if filter.GetMaxScreenSizeInch() > 0 {
+		where.add("COALESCE(s.size_inch, 0) <= ?", float64(filter.GetMaxScreenSizeInch()))
+	}
+	if resolution := filter.GetMinResolution(); resolution != nil {
+		where.add("COALESCE(s.width, 0) >= ? AND COALESCE(s.height, 0) >= ?", resolution.GetWidth(), resolution.GetHeight())
+	}
+	if resolution := filter.GetMaxResolution(); resolution != nil {
+		where.add("COALESCE(s.width, 0) <= ? AND COALESCE(s.height, 0) <= ?", resolution.GetWidth(), resolution.GetHeight())
+	}
+	addIn(where, "COALESCE(s.panel, 0)", filter.GetPanels())
+	if filter.Multitouch != nil {
+		where.add("COALESCE(s.multitouch, 0) = ?", filter.GetMultitouch())
+	}
+	addIn(where, "COALESCE(k.layout, 0)", filter.GetKeyboardLayouts())
+	if filter.KeyboardBacklit != nil {
+		where.add("COALESCE(k.backlit, 0) = ?", filter.GetKeyboardBacklit())
+	}
+
+	if filter.GetMinReleaseYear() > 0 {
+		where.add("l.release_year >= ?", filter.GetMinReleaseYear())
+	}
+	if filter.GetMaxReleaseYear() > 0 {
+		where.add("l.release_year <= ?", filter.GetMaxReleaseYear())
+	}
+	if maxWeight := units.MaxWeightKg(filter); maxWeight > 0 {
+		where.add(sqlWeightKg()+" <= ?", maxWeight)
+	}
+	return where
+}
+
+// addStorageCapacity adds the condition of hasStorageCapacity
+func addStorageCapacity(where *sqlWhere, driver pb.Storage_Driver, minCapacity *pb.Memory) {
+	if minCapacity == nil {
+		return
+	}
+	// TOTAL sums in floating point, where SUM fails on overflow
+	where.add(`(SELECT TOTAL(st.memory_bits) FROM storages st
+		WHERE st.laptop_id = l.id AND st.driver = ?) >= ?`, int32(driver), sqlMemoryBits(minCapacity))
+}
+
+// sortOrder returns the ORDER BY expressions of sortLaptops, ordering by id when unsorted
+func sortOrder(sortBy *pb.Sort) string {
+	var key string
+	switch sortBy.GetField() {
+	case pb.Sort_PRICE:
+		key = "l.price_usd"
+	case pb.Sort_CPU_GHZ:
+		key = "COALESCE(c.min_ghz, 0)"
+	case pb.Sort_RAM:
+		key = "l.ram_bits"
+	case pb.Sort_RELEASE_YEAR:
+		key = "l.release_year"
+	case pb.Sort_WEIGHT:
+		key = sqlWeightKg()
+	case pb.Sort_UPDATED_AT:
+		key = "COALESCE(l.updated_at, 0)"
+	default:
+		return "l.id"
+	}
+	if sortBy.GetDirection() == pb.Sort_DESCENDING {
+		return key + " DESC, l.id DESC"
+	}
+	return key + ", l.id"
+}
+
+// sqlMemoryBits returns the size in bits of the memory as an INTEGER of SQLite, which is signed.
+// Sizes from 2^63 bits (1 EiB) on are all stored as math.MaxInt64 and compare as equal.
+func sqlMemoryBits(memory *pb.Memory) int64 {
+	return int64(min(units.SaturatedBits(memory), math.MaxInt64))
+}
+
+// memoryColumns returns the value, unit and size in bits of the memory, with NULLs for a nil memory
+func memoryColumns(memory *pb.Memory) (sql.NullInt64, sql.NullInt32, int64) {
+	if memory == nil {
+		return sql.NullInt64{}, sql.NullInt32{}, 0
+	}
+	return sql.NullInt64{Int64: int64(memory.GetValue()), Valid: true},
+		sql.NullInt32{Int32: int32(memory.GetUnit()), Valid: true},
+		sqlMemoryBits(memory)
+}
+
+func scanMemory(value sql.NullInt64, unit sql.NullInt32) *pb.Memory {
+	if !value.Valid {
+		return nil
+	}
+	return &pb.Memory{Value: uint64(value.Int64), Unit: pb.Memory_Unit(unit.Int32)}
+}
+
+func insertLaptop(ctx context.Context, tx *sql.Tx, laptop *pb.Laptop) error {
+	ramValue, ramUnit, ramBits := memoryColumns(laptop.GetRam())
+	var weightKg, weightLb sql.NullFloat64
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		weightKg = sql.NullFloat64{Float64: weight.WeightKg, Valid: true}
+	case *pb.Laptop_WeightLb:
+		weightLb = sql.NullFloat64{Float64: weight.WeightLb, Valid: true}
+	}
+	var updatedAt sql.NullInt64
+	if laptop.GetUpdatedAt() != nil {
+		updatedAt = sql.NullInt64{Int64: laptop.GetUpdatedAt().AsTime().UnixNano(), Valid: true}
+	}
+
+	// a laptop saved concurrently by another transaction is only seen by the insert itself
+	result, err := tx.ExecContext(ctx, `INSERT INTO laptops (id, brand, name, ram_value, ram_unit, ram_bits,
+		weight_kg, weight_lb, price_usd, release_year, updated_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		laptop.GetId(), laptop.GetBrand(), laptop.GetName(), ramValue, ramUnit, ramBits,
+		weightKg, weightLb, laptop.GetPriceUsd(), laptop.GetReleaseYear(), updatedAt, laptop.GetVersion())
+	if err != nil {
+		return fmt.Errorf("can't insert laptop: %v", err)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("can't insert laptop: %v", err)
+	} else if count == 0 {
+		return ErrAlreadyExists
+	}
+
+	if cpu := laptop.GetCpu(); cpu != nil {
+		_, err := tx.ExecContext(ctx, `INSERT INTO cpus (laptop_id, brand, name, number_cores, number_threads,
+			min_ghz, max_ghz) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), cpu.GetBrand(), cpu.GetName(), cpu.GetNumberCores(), cpu.GetNumberThreads(),
+			cpu.GetMinGhz(), cpu.GetMaxGhz())
+		if err != nil {
+			return fmt.Errorf("can't insert cpu: %v", err)
+		}
+	}
+	for i, gpu := range laptop.GetGpus() {
+		memoryValue, memoryUnit, memoryBits := memoryColumns(gpu.GetMemory())
+		_, err := tx.ExecContext(ctx, `INSERT INTO gpus (laptop_id, position, brand, name, min_ghz, max_ghz,
+			memory_value, memory_unit, memory_bits) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), i, gpu.GetBrand(), gpu.GetName(), gpu.GetMinGhz(), gpu.GetMaxGhz(),
+			memoryValue, memoryUnit, memoryBits)
+		if err != nil {
+			return fmt.Errorf("can't insert gpu: %v", err)
+		}
+	}
+	for i, storage := range laptop.GetStorages() {
+		memoryValue, memoryUnit, memoryBits := memoryColumns(storage.GetMemory())
+		_, err := tx.ExecContext(ctx, `INSERT INTO storages (laptop_id, position, driver,
+			memory_value, memory_unit, memory_bits) VALUES (?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), i, int32(storage.GetDriver()), memoryValue, memoryUnit, memoryBits)
+		if err != nil {
+			return fmt.Errorf("can't insert storage: %v", err)
+		}
+	}
+	if screen := laptop.GetScreen(); screen != nil {
+		var width, height sql.NullInt64
+		if resolution := screen.GetResolution(); resolution != nil {
+			width = sql.NullInt64{Int64: int64(resolution.GetWidth()), Valid: true}
+			height = sql.NullInt64{Int64: int64(resolution.GetHeight()), Valid: true}
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO screens (laptop_id, size_inch, width, height, panel, multitouch)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), float64(screen.GetSizeInch()), width, height, int32(screen.GetPanel()), screen.GetMultitouch())
+		if err != nil {
+			return fmt.Errorf("can't insert screen: %v", err)
+		}
+	}
+	if keyboard := laptop.GetKeyboard(); keyboard != nil {
+		_, err := tx.ExecContext(ctx, `INSERT INTO keyboards (laptop_id, layout, backlit) VALUES (?, ?, ?)`,
+			laptop.GetId(), int32(keyboard.GetLayout()), keyboard.GetBacklit())
+		if err != nil {
+			return fmt.Errorf("can't insert keyboard: %v", err)
+		}
+	}
+	return nil
+}
+
+// deleteLaptop deletes the rows of the laptop, failing with ErrVersionMismatch
+// if its version changed since it was read
+func deleteLaptop(ctx context.Context, tx *sql.Tx, laptop *pb.Laptop) error {
+	for _, table := range []string{"cpus", "gpus", "storages", "screens", "keyboards"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE laptop_id = ?", laptop.GetId()); err != nil {
+			return fmt.Errorf("can't delete from %s: %v", table, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM laptops WHERE id = ? AND version = ?`,
+		laptop.GetId(), laptop.GetVersion())
+	if err != nil {
+		return fmt.Errorf("can't delete laptop: %v", err)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("can't delete laptop: %v", err)
+	} else if count == 0 {
+		return fmt.Errorf("%w: laptop %s changed concurrently", ErrVersionMismatch, laptop.GetId())
+	}
+	return nil
+}
+
+func findLaptop(ctx context.Context, querier sqlQuerier, id string) (*pb.Laptop, error) {
+	laptops, err := selectLaptops(ctx, querier, `WHERE l.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(laptops) == 0 {
+		return nil, ErrNotFound
+	}
+	return laptops[0], nil
+}
+
+// selectLaptops returns the laptops selected by the clause with their GPUs and storages
+func selectLaptops(ctx context.Context, querier sqlQuerier, clause string, args ...any) ([]*pb.Laptop, error) {
+	rows, err := querier.QueryContext(ctx, "SELECT "+laptopColumns+" FROM "+laptopTables+" "+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't select laptops: %v", err)
+	}
+	defer rows.Close()
+
+	laptops := []*pb.Laptop{}
+	byId := make(map[string]*pb.Laptop)
+	for rows.Next() {
+		laptop, err := scanLaptop(rows)
+		if err != nil {
+			return nil, fmt.Errorf("can't read laptop: %v", err)
+		}
+		laptops = append(laptops, laptop)
+		byId[laptop.Id] = laptop
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("can't select laptops: %v", err)
+	}
+	rows.Close()
+
+	for start := 0; start < len(laptops); start += maxSqlParameters {
+		ids := make([]any, 0, maxSqlParameters)
+		for _, laptop := range laptops[start:min(start+maxSqlParameters, len(laptops))] {
+			ids = append(ids, laptop.Id)
+		}
+		if err := selectGpus(ctx, querier, byId, ids); err != nil {
+			return nil, err
+		}
+		if err := selectStorages(ctx, querier, byId, ids); err != nil {
+			return nil, err
+		}
+	}
+	return laptops, nil
+}
+
+func scanLaptop(rows *sql.Rows) (*pb.Laptop, error) {
+	laptop := &pb.Laptop{}
+	var ramValue sql.NullInt64
+	var ramUnit sql.NullInt32
+	var weightKg, weightLb sql.NullFloat64
+	var updatedAt sql.NullInt64
+	var cpuId, cpuBrand, cpuName sql.NullString
+	var cpuCores, cpuThreads sql.NullInt64
+	var cpuMinGhz, cpuMaxGhz sql.NullFloat64
+	var screenId sql.NullString
+	var screenSize sql.NullFloat64
+	var screenWidth, screenHeight sql.NullInt64
+	var screenPanel sql.NullInt32
+	var screenMultitouch sql.NullBool
+	var keyboardId sql.NullString
+	var keyboardLayout sql.NullInt32
+	var keyboardBacklit sql.NullBool
+
+	err := rows.Scan(&laptop.Id, &laptop.Brand, &laptop.Name, &ramValue, &ramUnit, &weightKg, &weightLb,
+		&laptop.PriceUsd, &laptop.ReleaseYear, &updatedAt, &laptop.Version,
+		&cpuId, &cpuBrand, &cpuName, &cpuCores, &cpuThreads, &cpuMinGhz, &cpuMaxGhz,
+		&screenId, &screenSize, &screenWidth, &screenHeight, &screenPanel, &screenMultitouch,
+		&keyboardId, &keyboardLayout, &keyboardBacklit)
+	if err != nil {
+		return nil, err
+	}
+
+	laptop.Ram = scanMemory(ramValue, ramUnit)
+	if weightKg.Valid {
+		laptop.Weight = &pb.Laptop_WeightKg{WeightKg: weightKg.Float64}
+	} else if weightLb.Valid {
+		laptop.Weight = &pb.Laptop_WeightLb{WeightLb: weightLb.Float64}
+	}
+	if updatedAt.Valid {
+		laptop.UpdatedAt = timestamppb.New(time.Unix(0, updatedAt.Int64))
+	}
+	if cpuId.Valid {
+		laptop.Cpu = &pb.CPU{
+			Brand:         cpuBrand.String,
+			Name:          cpuName.String,
+			NumberCores:   uint32(cpuCores.Int64),
+			NumberThreads: uint32(cpuThreads.Int64),
+			MinGhz:        cpuMinGhz.Float64,
+			MaxGhz:        cpuMaxGhz.Float64,
+		}
+	}
+	if screenId.Valid {
+		laptop.Screen = &pb.Screen{
+			SizeInch:   float32(screenSize.Float64),
+			Panel:      pb.Screen_Panel(screenPanel.Int32),
+			Multitouch: screenMultitouch.Bool,
+		}
+		if screenWidth.Valid {
+			laptop.Screen.Resolution = &pb.Screen_Resolution{
+				Width:  uint32(screenWidth.Int64),
+				Height: uint32(screenHeight.Int64),
+			}
+		}
+	}
+	if keyboardId.Valid {
+		laptop.Keyboard = &pb.Keyboard{
+			Layout:  pb.Keyboard_Layout(keyboardLayout.Int32),
+			Backlit: keyboardBacklit.Bool,
+		}
+	}
+	return laptop, nil
+}
+
+func selectGpus(ctx context.Context, querier sqlQuerier, laptops map[string]*pb.Laptop, ids []any) error {
+	rows, err := querier.QueryContext(ctx, `SELECT laptop_id, brand, name, min_ghz, max_ghz, memory_value, memory_unit
+		FROM gpus WHERE laptop_id IN (`+placeholders(len(ids))+`) ORDER BY laptop_id, position`, ids...)
+	if err != nil {
+		return fmt.Errorf("can't select gpus: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var laptopId string
+		var memoryValue sql.NullInt64
+		var memoryUnit sql.NullInt32
+		gpu := &pb.GPU{}
+		if err := rows.Scan(&laptopId, &gpu.Brand, &gpu.Name, &gpu.MinGhz, &gpu.MaxGhz, &memoryValue, &memoryUnit); err != nil {
+			return fmt.Errorf("can't read gpu: %v", err)
+		}
+		gpu.Memory = scanMemory(memoryValue, memoryUnit)
+		laptops[laptopId].Gpus = append(laptops[laptopId].Gpus, gpu)
+	}
+	return rows.Err()
+}
+
+func selectStorages(ctx context.Context, querier sqlQuerier, laptops map[string]*pb.Laptop, ids []any) error {
+	rows, err := querier.QueryContext(ctx, `SELECT laptop_id, driver, memory_value, memory_unit
+		FROM storages WHERE laptop_id IN (`+placeholders(len(ids))+`) ORDER BY laptop_id, position`, ids...)
+	if err != nil {
+		return fmt.Errorf("can't select storages: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var laptopId string
+		var driver int32
+		var memoryValue sql.NullInt64
+		var memoryUnit sql.NullInt32
+		if err := rows.Scan(&laptopId, &driver, &memoryValue, &memoryUnit); err != nil {
+			return fmt.Errorf("can't read storage: %v", err)
+		}
+		laptops[laptopId].Storages = append(laptops[laptopId].Storages, &pb.Storage{
+			Driver: pb.Storage_Driver(driver),
+			Memory: scanMemory(memoryValue, memoryUnit),
+		})
+	}
+	return rows.Err()
+}
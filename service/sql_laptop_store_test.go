@@ -0,0 +1,113 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterToWhere(t *testing.T) {
+	t.Parallel()
+
+	base := []string{
+		"l.price_usd <= ?",
+		"COALESCE(c.number_cores, 0) >= ?",
+		"COALESCE(c.min_ghz, 0) >= ?",
+		"l.ram_bits >= ?",
+	}
+	baseArgs := []any{float64(2000), uint32(4), 2.5, int64(8 << 33)}
+
+	testCases := []struct {
+		name       string
+		filter     func(filter *pb.Filter)
+		conditions []string
+		args       []any
+	}{
+		{
+			name:   "base_filter",
+			filter: func(filter *pb.Filter) {},
+		},
+		{
+			name:       "brands_ignoring_case",
+			filter:     func(filter *pb.Filter) { filter.Brands = []string{"Dell", "LENOVO"} },
+			conditions: []string{"LOWER(l.brand) IN (?, ?)"},
+			args:       []any{"dell", "lenovo"},
+		},
+		{
+			name: "gpu_memory_and_brand",
+			filter: func(filter *pb.Filter) {
+				filter.MinGpuMemory = &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}
+				filter.GpuBrands = []string{"NVIDIA"}
+			},
+			conditions: []string{"EXISTS (SELECT 1 FROM gpus g WHERE g.laptop_id = l.id AND g.memory_bits >= ? AND LOWER(g.brand) IN (?))"},
+			args:       []any{int64(8 << 33), "nvidia"},
+		},
+		{
+			name:       "panels",
+			filter:     func(filter *pb.Filter) { filter.Panels = []pb.Screen_Panel{pb.Screen_IPS, pb.Screen_OLED} },
+			conditions: []string{"COALESCE(s.panel, 0) IN (?, ?)"},
+			args:       []any{pb.Screen_IPS, pb.Screen_OLED},
+		},
+		{
+			name: "multitouch_false",
+			filter: func(filter *pb.Filter) {
+				multitouch := false
+				filter.Multitouch = &multitouch
+			},
+			conditions: []string{"COALESCE(s.multitouch, 0) = ?"},
+			args:       []any{false},
+		},
+		{
+			name: "release_years",
+			filter: func(filter *pb.Filter) {
+				filter.MinReleaseYear = 2018
+				filter.MaxReleaseYear = 2020
+			},
+			conditions: []string{"l.release_year >= ?", "l.release_year <= ?"},
+			args:       []any{uint32(2018), uint32(2020)},
+		},
+		{
+			name:       "max_weight",
+			filter:     func(filter *pb.Filter) { filter.MaxWeight = &pb.Filter_MaxWeightKg{MaxWeightKg: 2} },
+			conditions: []string{"COALESCE(l.weight_kg, l.weight_lb * 0.45359237, 0) <= ?"},
+			args:       []any{2.0},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := &pb.Filter{
+				MaxPriceUsd: 2000,
+				MinCpuCores: 4,
+				MinCpuGhz:   2.5,
+				MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+			}
+			tc.filter(filter)
+
+			where := filterToWhere(filter)
+			require.Equal(t, append(append([]string{}, base...), tc.conditions...), where.conditions)
+			require.Equal(t, append(append([]any{}, baseArgs...), tc.args...), where.args)
+		})
+	}
+}
+
+func TestFilterToWhereNil(t *testing.T) {
+	t.Parallel()
+
+	where := filterToWhere(nil)
+	require.Empty(t, where.clause())
+	require.Empty(t, where.args)
+}
+
+func TestSortOrder(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "l.id", sortOrder(nil))
+	require.Equal(t, "l.price_usd, l.id", sortOrder(&pb.Sort{Field: pb.Sort_PRICE}))
+	require.Equal(t, "l.release_year DESC, l.id DESC",
+		sortOrder(&pb.Sort{Field: pb.Sort_RELEASE_YEAR, Direction: pb.Sort_DESCENDING}))
+}
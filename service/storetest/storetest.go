@@ -0,0 +1,478 @@
+// Package storetest checks that a service.LaptopStore behaves like the stores of the service package.
+//
+// A new store runs the suite from its tests:
+//
+//	storetest.RunLaptopStoreSuite(t, func(t *testing.T) service.LaptopStore {
+//		return NewMyLaptopStore()
+//	})
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/query"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/pokala15/pcbook/service"
+	"github.com/pokala15/pcbook/validation"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Factory returns a new empty store for a test
+type Factory func(t *testing.T) service.LaptopStore
+
+// RunLaptopStoreSuite runs every behaviour of the LaptopStore interface as a subtest,
+// each on its own store. Run the tests with -race to check the concurrency test.
+func RunLaptopStoreSuite(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store service.LaptopStore)
+	}{
+		{"save_and_find", testSaveAndFind},
+		{"save_duplicate", testSaveDuplicate},
+		{"save_concurrent_duplicates", testSaveConcurrentDuplicates},
+		{"find_missing", testFindMissing},
+		{"copies", testCopies},
+		{"update", testUpdate},
+		{"update_errors", testUpdateErrors},
+		{"delete", testDelete},
+		{"list", testList},
+		{"search_filter", testSearchFilter},
+		{"search_options", testSearchOptions},
+		{"search_copies", testSearchCopies},
+		{"search_large_memories", testSearchLargeMemories},
+		{"search_cancelled", testSearchCancelled},
+		{"search_callback_error", testSearchCallbackError},
+		{"concurrency", testConcurrency},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.test(t, factory(t))
+		})
+	}
+}
+
+// saveLaptops saves the laptops and returns them as stored
+func saveLaptops(t *testing.T, store service.LaptopStore, laptops ...*pb.Laptop) []*pb.Laptop {
+	saved := make([]*pb.Laptop, len(laptops))
+	for i, laptop := range laptops {
+		require.NoError(t, store.Save(laptop))
+		stored, err := store.FindById(laptop.Id)
+		require.NoError(t, err)
+		saved[i] = stored
+	}
+	return saved
+}
+
+func requireEqualMessage(t *testing.T, expected proto.Message, actual proto.Message) {
+	require.True(t, proto.Equal(expected, actual), "expected %v, got %v", expected, actual)
+}
+
+// search returns the ids of the laptops found, in order
+func search(t *testing.T, store service.LaptopStore, filter *pb.Filter, options service.SearchOptions) []string {
+	ids := []string{}
+	err := store.Search(filter, options, context.Background(), func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.Id)
+		return nil
+	})
+	require.NoError(t, err)
+	return ids
+}
+
+func testSaveAndFind(t *testing.T, store service.LaptopStore) {
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	stored, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stored.Version)
+
+	expected := proto.Clone(laptop).(*pb.Laptop)
+	expected.Version = 1
+	requireEqualMessage(t, expected, stored)
+}
+
+func testSaveDuplicate(t *testing.T, store service.LaptopStore) {
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	other := sample.NewLaptop()
+	other.Id = laptop.Id
+	require.ErrorIs(t, store.Save(other), service.ErrAlreadyExists)
+
+	stored, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, laptop.Name, stored.Name)
+}
+
+// testSaveConcurrentDuplicates saves the same id from several goroutines, only one of them may win
+func testSaveConcurrentDuplicates(t *testing.T, store service.LaptopStore) {
+	const workers = 8
+	id := sample.NewLaptop().Id
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			laptop := sample.NewLaptop()
+			laptop.Id = id
+			errs <- store.Save(laptop)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+		require.ErrorIs(t, err, service.ErrAlreadyExists)
+	}
+	require.Equal(t, 1, saved)
+}
+
+func testFindMissing(t *testing.T, store service.LaptopStore) {
+	laptop, err := store.FindById(sample.NewLaptop().Id)
+	require.ErrorIs(t, err, service.ErrNotFound)
+	require.Nil(t, laptop)
+}
+
+func testCopies(t *testing.T, store service.LaptopStore) {
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+	saved, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	expected := proto.Clone(saved).(*pb.Laptop)
+
+	// neither the saved laptop nor a found one share memory with the stored laptop
+	laptop.Cpu.Name = "changed"
+	laptop.Gpus[0].Memory.Value++
+	saved.Storages[0].Memory.Value++
+	saved.Screen.Resolution.Width++
+
+	stored, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, expected, stored)
+}
+
+func testUpdate(t *testing.T, store service.LaptopStore) {
+	saved := saveLaptops(t, store, sample.NewLaptop())[0]
+
+	changes := sample.NewLaptop()
+	changes.Id = saved.Id
+	changes.PriceUsd = 1234
+	// the cores are merged alone, so they must stay within the stored threads
+	changes.Cpu.NumberCores = min(changes.Cpu.NumberCores, saved.Cpu.NumberThreads)
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "cpu.number_cores"}}
+
+	updated, err := store.Update(changes, mask, saved.Version)
+	require.NoError(t, err)
+	require.Equal(t, saved.Version+1, updated.Version)
+	require.Equal(t, 1234.0, updated.PriceUsd)
+	require.Equal(t, changes.Cpu.NumberCores, updated.Cpu.NumberCores)
+	require.Equal(t, saved.Cpu.Name, updated.Cpu.Name)
+	require.Equal(t, saved.Name, updated.Name)
+	require.NotNil(t, updated.UpdatedAt)
+
+	stored, err := store.FindById(saved.Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, updated, stored)
+
+	// an empty mask replaces every field that can be updated, ignoring the version
+	updated, err = store.Update(changes, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, saved.Version+2, updated.Version)
+	require.Equal(t, changes.Name, updated.Name)
+	requireEqualMessage(t, changes.Gpus[0], updated.Gpus[0])
+}
+
+func testUpdateErrors(t *testing.T, store service.LaptopStore) {
+	saved := saveLaptops(t, store, sample.NewLaptop())[0]
+
+	_, err := store.Update(sample.NewLaptop(), nil, 0)
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	_, err = store.Update(saved, nil, saved.Version+1)
+	require.ErrorIs(t, err, service.ErrVersionMismatch)
+
+	_, err = store.Update(saved, &fieldmaskpb.FieldMask{Paths: []string{"unknown"}}, 0)
+	require.ErrorIs(t, err, service.ErrInvalidFieldMask)
+
+	_, err = store.Update(saved, &fieldmaskpb.FieldMask{Paths: []string{"version"}}, 0)
+	require.ErrorIs(t, err, service.ErrInvalidFieldMask)
+
+	// the merged laptop must be valid, not only the changes
+	changes := sample.NewLaptop()
+	changes.Id = saved.Id
+	changes.Cpu.MinGhz = saved.Cpu.MaxGhz + 1
+	_, err = store.Update(changes, &fieldmaskpb.FieldMask{Paths: []string{"cpu.min_ghz"}}, 0)
+	var validationErr *validation.Error
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "cpu.max_ghz", validationErr.Violations[0].Field)
+
+	// failed updates leave the laptop unchanged
+	stored, err := store.FindById(saved.Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, saved, stored)
+}
+
+func testDelete(t *testing.T, store service.LaptopStore) {
+	saved := saveLaptops(t, store, sample.NewLaptop(), sample.NewLaptop())
+
+	require.ErrorIs(t, store.Delete(saved[0].Id, saved[0].Version+1), service.ErrVersionMismatch)
+	require.NoError(t, store.Delete(saved[0].Id, saved[0].Version))
+	require.ErrorIs(t, store.Delete(saved[0].Id, 0), service.ErrNotFound)
+
+	_, err := store.FindById(saved[0].Id)
+	require.ErrorIs(t, err, service.ErrNotFound)
+	stored, err := store.FindById(saved[1].Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, saved[1], stored)
+
+	// the id can be used again
+	require.NoError(t, store.Save(saved[0]))
+}
+
+func testList(t *testing.T, store service.LaptopStore) {
+	var ids []string
+	for i := 0; i < 5; i++ {
+		laptop := sample.NewLaptop()
+		saveLaptops(t, store, laptop)
+		ids = append(ids, laptop.Id)
+	}
+	sort.Strings(ids)
+
+	var listed []string
+	afterId := ""
+	for {
+		laptops, err := store.List(afterId, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(laptops), 2)
+		if len(laptops) == 0 {
+			break
+		}
+		for _, laptop := range laptops {
+			listed = append(listed, laptop.Id)
+		}
+		afterId = laptops[len(laptops)-1].Id
+	}
+	require.Equal(t, ids, listed)
+}
+
+func testSearchFilter(t *testing.T, store service.LaptopStore) {
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	cheap.Brand = "Dell"
+	cheap.Cpu.NumberCores = 8
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 3000
+	expensive.Brand = "Dell"
+	expensive.Cpu.NumberCores = 8
+	otherBrand := sample.NewLaptop()
+	otherBrand.PriceUsd = 1000
+	otherBrand.Brand = "Apple"
+	otherBrand.Cpu.NumberCores = 8
+	fewCores := sample.NewLaptop()
+	fewCores.PriceUsd = 1000
+	fewCores.Brand = "Dell"
+	fewCores.Cpu.NumberCores = 2
+	saveLaptops(t, store, cheap, expensive, otherBrand, fewCores)
+
+	filter := &pb.Filter{MaxPriceUsd: 2000, MinCpuCores: 4, Brands: []string{"dell"}}
+	require.Equal(t, []string{cheap.Id}, search(t, store, filter, service.SearchOptions{}))
+
+	// a nil filter matches every laptop
+	require.Len(t, search(t, store, nil, service.SearchOptions{}), 4)
+}
+
+func testSearchOptions(t *testing.T, store service.LaptopStore) {
+	laptops := make([]*pb.Laptop, 4)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		laptops[i].PriceUsd = float64(1000 * (len(laptops) - i))
+		laptops[i].Brand = "Brand"
+		laptops[i].Name = fmt.Sprintf("Model %d", i)
+	}
+	laptops[3].Name = "Ultrabook"
+	saveLaptops(t, store, laptops...)
+
+	byPrice := service.SearchOptions{SortBy: &pb.Sort{Field: pb.Sort_PRICE}, MaxResults: 3}
+	require.Equal(t, []string{laptops[3].Id, laptops[2].Id, laptops[1].Id}, search(t, store, nil, byPrice))
+
+	byPrice.SortBy.Direction = pb.Sort_DESCENDING
+	require.Equal(t, []string{laptops[0].Id, laptops[1].Id, laptops[2].Id}, search(t, store, nil, byPrice))
+
+	predicate, err := query.Parse("price<2500")
+	require.NoError(t, err)
+	byQuery := service.SearchOptions{Query: predicate, SortBy: &pb.Sort{Field: pb.Sort_PRICE}}
+	require.Equal(t, []string{laptops[3].Id, laptops[2].Id}, search(t, store, nil, byQuery))
+
+	byText := service.SearchOptions{Text: "ultra"}
+	require.Equal(t, []string{laptops[3].Id}, search(t, store, nil, byText))
+
+	// the filter, the query and the text all apply before the sort and the limit
+	byQuery.SortBy.Direction = pb.Sort_DESCENDING
+	byQuery.MaxResults = 1
+	require.Equal(t, []string{laptops[3].Id}, search(t, store, &pb.Filter{MaxPriceUsd: 1500}, byQuery))
+
+	byText = service.SearchOptions{
+		Text:       "model",
+		SortBy:     &pb.Sort{Field: pb.Sort_PRICE, Direction: pb.Sort_DESCENDING},
+		MaxResults: 2,
+	}
+	require.Equal(t, []string{laptops[1].Id, laptops[2].Id}, search(t, store, &pb.Filter{MaxPriceUsd: 3500}, byText))
+}
+
+func testSearchCopies(t *testing.T, store service.LaptopStore) {
+	saved := saveLaptops(t, store, sample.NewLaptop())[0]
+
+	err := store.Search(nil, service.SearchOptions{}, context.Background(), func(laptop *pb.Laptop) error {
+		laptop.Cpu.Name = "changed"
+		laptop.Gpus[0].Memory.Value++
+		return nil
+	})
+	require.NoError(t, err)
+
+	stored, err := store.FindById(saved.Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, saved, stored)
+}
+
+// testSearchLargeMemories searches with sizes that overflow 64 bits, which validation rejects
+// in laptops but not in filters, and stores sizes that overflow signed 64 bits integers
+func testSearchLargeMemories(t *testing.T, store service.LaptopStore) {
+	huge := &pb.Memory{Value: 1 << 30, Unit: pb.Memory_TERABYTE}
+	large := &pb.Memory{Value: 1 << 20, Unit: pb.Memory_TERABYTE} // 2^63 bits
+	laptop := saveLaptops(t, store, sample.NewLaptop())[0]
+
+	require.Empty(t, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinRam: huge}, service.SearchOptions{}))
+	require.Empty(t, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinGpuMemory: huge}, service.SearchOptions{}))
+	require.Empty(t, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinSsdCapacity: huge}, service.SearchOptions{}))
+
+	laptop.Ram = large
+	laptop.Storages = []*pb.Storage{
+		{Driver: pb.Storage_SSD, Memory: large},
+		{Driver: pb.Storage_SSD, Memory: large},
+	}
+	updated, err := store.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"ram", "storages"}}, laptop.Version)
+	require.NoError(t, err)
+	stored, err := store.FindById(laptop.Id)
+	require.NoError(t, err)
+	requireEqualMessage(t, updated, stored)
+
+	terabyte := &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}
+	require.Equal(t, []string{laptop.Id}, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinRam: terabyte}, service.SearchOptions{}))
+	require.Equal(t, []string{laptop.Id}, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinSsdCapacity: terabyte}, service.SearchOptions{}))
+	require.Equal(t, []string{laptop.Id}, search(t, store, &pb.Filter{MaxPriceUsd: 5000, MinRam: large}, service.SearchOptions{}))
+}
+
+func testSearchCancelled(t *testing.T, store service.LaptopStore) {
+	saveLaptops(t, store, sample.NewLaptop(), sample.NewLaptop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := store.Search(nil, service.SearchOptions{}, ctx, func(laptop *pb.Laptop) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// cancelling while streaming stops the search
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	err = store.Search(nil, service.SearchOptions{}, ctx, func(laptop *pb.Laptop) error {
+		calls++
+		cancel()
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, calls)
+}
+
+func testSearchCallbackError(t *testing.T, store service.LaptopStore) {
+	saveLaptops(t, store, sample.NewLaptop(), sample.NewLaptop())
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := store.Search(nil, service.SearchOptions{}, context.Background(), func(laptop *pb.Laptop) error {
+		calls++
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1, calls)
+}
+
+func testConcurrency(t *testing.T, store service.LaptopStore) {
+	const workers = 8
+	const laptopsPerWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- runWorker(store, laptopsPerWorker)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	laptops, err := store.List("", workers*laptopsPerWorker+1)
+	require.NoError(t, err)
+	require.Len(t, laptops, workers*laptopsPerWorker/2)
+	for _, laptop := range laptops {
+		require.Equal(t, uint64(2), laptop.Version)
+	}
+}
+
+// runWorker saves laptops, updates them, deletes every other one and searches in between
+func runWorker(store service.LaptopStore, count int) error {
+	for i := 0; i < count; i++ {
+		laptop := sample.NewLaptop()
+		if err := store.Save(laptop); err != nil {
+			return err
+		}
+		laptop.PriceUsd++
+		if _, err := store.Update(laptop, &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}}, 1); err != nil {
+			return err
+		}
+		if i%2 == 1 {
+			if err := store.Delete(laptop.Id, 2); err != nil {
+				return err
+			}
+		}
+
+		err := store.Search(&pb.Filter{MaxPriceUsd: 5000}, service.SearchOptions{}, context.Background(),
+			func(found *pb.Laptop) error {
+				if found.Cpu == nil {
+					return fmt.Errorf("laptop %s found without cpu", found.Id)
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+		if _, err := store.List("", 10); err != nil {
+			return err
+		}
+	}
+	return nil
+}
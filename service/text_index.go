@@ -0,0 +1,109 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pokala15/pcbook/pb"
+)
+
+// textIndex is an inverted index of the words in laptop, CPU and GPU names
+type textIndex struct {
+	postings map[string]map[string]int // word -> laptop id -> occurrences
+	words    *orderedSet[string]       // keys of postings in order, for prefix lookups
+	laptops  map[string][]string       // laptop id -> indexed words, to remove them again
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: make(map[string]map[string]int),
+		words:    newOrderedSet(strings.Compare),
+		laptops:  make(map[string][]string),
+	}
+}
+
+// tokenize splits the text into lower case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func laptopWords(laptop *pb.Laptop) []string {
+	words := tokenize(laptop.GetBrand())
+	words = append(words, tokenize(laptop.GetName())...)
+	words = append(words, tokenize(laptop.GetCpu().GetBrand())...)
+	words = append(words, tokenize(laptop.GetCpu().GetName())...)
+	for _, gpu := range laptop.GetGpus() {
+		words = append(words, tokenize(gpu.GetBrand())...)
+		words = append(words, tokenize(gpu.GetName())...)
+	}
+	return words
+}
+
+// add indexes the laptop, replacing what was indexed before for the same id
+func (index *textIndex) add(laptop *pb.Laptop) {
+	index.remove(laptop.GetId())
+
+	words := laptopWords(laptop)
+	for _, word := range words {
+		ids, ok := index.postings[word]
+		if !ok {
+			ids = make(map[string]int)
+			index.postings[word] = ids
+			index.words.add(word)
+		}
+		ids[laptop.GetId()]++
+	}
+	index.laptops[laptop.GetId()] = words
+}
+
+func (index *textIndex) remove(id string) {
+	for _, word := range index.laptops[id] {
+		ids := index.postings[word]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(index.postings, word)
+			index.words.remove(word)
+		}
+	}
+	delete(index.laptops, id)
+}
+
+// search returns the relevance of the laptops in which every word of the text
+// appears, either as a whole word or as the prefix of a word.
+// Whole words score higher than prefixes, and repeated words score higher than single ones.
+func (index *textIndex) search(text string) map[string]float64 {
+	var scores map[string]float64
+	for _, queryWord := range tokenize(text) {
+		wordScores := make(map[string]float64)
+		i := index.words.rank(func(word string) bool {
+			return word < queryWord
+		})
+		for word := range index.words.from(i) {
+			if !strings.HasPrefix(word, queryWord) {
+				break
+			}
+			weight := 1.0
+			if word == queryWord {
+				weight = 2.0
+			}
+			for id, occurrences := range index.postings[word] {
+				wordScores[id] += weight * float64(occurrences)
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id, score := range scores {
+			if wordScore, ok := wordScores[id]; ok {
+				scores[id] = score + wordScore
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextIndex(t *testing.T) {
+	t.Parallel()
+
+	thinkpad := sample.NewLaptop()
+	thinkpad.Brand = "Lenovo"
+	thinkpad.Name = "Thinkpad X1"
+	thinkpad.Gpus = []*pb.GPU{{Brand: "NVIDIA", Name: "RTX 2070"}}
+
+	thinkpadPro := sample.NewLaptop()
+	thinkpadPro.Brand = "Lenovo"
+	thinkpadPro.Name = "ThinkPad P53 Thinkpad"
+	thinkpadPro.Gpus = []*pb.GPU{{Brand: "NVIDIA", Name: "RTX 2060"}}
+
+	xps := sample.NewLaptop()
+	xps.Brand = "Dell"
+	xps.Name = "XPS"
+	xps.Gpus = []*pb.GPU{{Brand: "AMD", Name: "RX 5700-XT"}}
+
+	index := newTextIndex()
+	for _, laptop := range []*pb.Laptop{thinkpad, thinkpadPro, xps} {
+		index.add(laptop)
+	}
+
+	scores := index.search("THINKPAD")
+	require.Len(t, scores, 2)
+	require.Greater(t, scores[thinkpadPro.Id], scores[thinkpad.Id])
+
+	scores = index.search("rtx 2070")
+	require.Len(t, scores, 1)
+	require.Contains(t, scores, thinkpad.Id)
+
+	// prefixes match, but score lower than whole words
+	scores = index.search("rtx 20")
+	require.Len(t, scores, 2)
+	scores = index.search("5700-xt")
+	require.Len(t, scores, 1)
+	require.Contains(t, scores, xps.Id)
+
+	require.Empty(t, index.search("macbook"))
+	require.Nil(t, index.search(" - "))
+
+	xps.Name = "Latitude"
+	index.add(xps)
+	require.Empty(t, index.search("xps"))
+	require.Len(t, index.search("latitude"), 1)
+
+	index.remove(xps.Id)
+	require.Empty(t, index.search("latitude"))
+	require.False(t, index.words.contains("latitude"))
+}
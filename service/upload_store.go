@@ -0,0 +1,304 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pokala15/pcbook/pb"
+)
+
+var (
+	ErrInvalidOffset  = errors.New("offset is past the received data")
+	ErrUploadFinished = errors.New("upload is finished")
+)
+
+// UploadStore keeps the data of images uploaded in several streams until they are complete
+type UploadStore interface {
+	// Start creates an empty upload of an image of the laptop and returns its id
+	Start(laptopId string, imageType pb.ImageType, primary bool) (string, error)
+	// Find fails with ErrNotFound if no upload has the id
+	Find(uploadId string) (*Upload, error)
+	// Write replaces the data received from offset on with the data read, and returns the number
+	// of bytes received in total. What is read before a failure is kept, so that the upload can
+	// be resumed. It fails with ErrInvalidOffset if offset is past the received data,
+	// and with ErrUploadFinished once the upload is finished.
+	Write(uploadId string, offset int64, data io.Reader) (int64, error)
+	// Open returns the upload and a reader of its data. The upload can't be written, opened
+	// or deleted until the reader is closed. It fails with ErrUploadFinished once the upload is finished.
+	Open(uploadId string) (*Upload, UploadReader, error)
+	Delete(uploadId string) error
+}
+
+// UploadReader reads the data of an upload while holding it
+type UploadReader interface {
+	io.ReadSeekCloser
+	// Finish records that the upload is stored as the image and drops its data,
+	// before anyone else can open the upload
+	Finish(imageId string) error
+}
+
+type Upload struct {
+	Id       string
+	LaptopId string
+	Type     pb.ImageType
+	Primary  bool
+	Received int64
+	ImageId  string // set once the upload is finished
+}
+
+// DiskUploadStore keeps the uploads in files of a folder. Uploads that are left alone
+// for longer than the ttl are removed by RemoveExpired, finished or not.
+type DiskUploadStore struct {
+	mutex        sync.RWMutex
+	uploadFolder string
+	ttl          time.Duration
+	uploads      map[string]*diskUpload
+}
+
+type diskUpload struct {
+	mutex     sync.Mutex // held while the data is written or read
+	upload    Upload
+	path      string
+	touchedAt time.Time // guarded by the mutex of the store
+}
+
+// NewDiskUploadStore returns a store of uploads in the folder, after removing the upload files
+// of a previous run: uploads are only known in memory, so they can't be resumed anyway
+func NewDiskUploadStore(uploadFolder string, ttl time.Duration) (*DiskUploadStore, error) {
+	paths, err := filepath.Glob(filepath.Join(uploadFolder, "*.upload"))
+	if err != nil {
+		return nil, fmt.Errorf("error while listing uploads: %v", err)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error while deleting stale upload: %v", err)
+		}
+	}
+
+	return &DiskUploadStore{
+		uploadFolder: uploadFolder,
+		ttl:          ttl,
+		uploads:      make(map[string]*diskUpload),
+	}, nil
+}
+
+func (uploadStore *DiskUploadStore) Start(laptopId string, imageType pb.ImageType, primary bool) (string, error) {
+	uploadId, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("error while creating uploadId: %v", err)
+	}
+	uploadPath := filepath.Join(uploadStore.uploadFolder, uploadId.String()+".upload")
+
+	file, err := os.Create(uploadPath)
+	if err != nil {
+		return "", fmt.Errorf("error while creating file: %v", err)
+	}
+	file.Close()
+
+	uploadStore.mutex.Lock()
+	defer uploadStore.mutex.Unlock()
+
+	uploadStore.uploads[uploadId.String()] = &diskUpload{
+		upload: Upload{
+			Id:       uploadId.String(),
+			LaptopId: laptopId,
+			Type:     imageType,
+			Primary:  primary,
+		},
+		path:      uploadPath,
+		touchedAt: time.Now(),
+	}
+	return uploadId.String(), nil
+}
+
+func (uploadStore *DiskUploadStore) Find(uploadId string) (*Upload, error) {
+	uploadStore.mutex.RLock()
+	defer uploadStore.mutex.RUnlock()
+
+	stored, ok := uploadStore.uploads[uploadId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	other := stored.upload
+	return &other, nil
+}
+
+// lock returns the upload with its mutex held
+func (uploadStore *DiskUploadStore) lock(uploadId string) (*diskUpload, error) {
+	uploadStore.mutex.RLock()
+	stored, ok := uploadStore.uploads[uploadId]
+	uploadStore.mutex.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	stored.mutex.Lock()
+	uploadStore.mutex.RLock()
+	_, ok = uploadStore.uploads[uploadId]
+	uploadStore.mutex.RUnlock()
+	if !ok {
+		// deleted while waiting for the lock
+		stored.mutex.Unlock()
+		return nil, ErrNotFound
+	}
+	return stored, nil
+}
+
+func (uploadStore *DiskUploadStore) Write(uploadId string, offset int64, data io.Reader) (int64, error) {
+	stored, err := uploadStore.lock(uploadId)
+	if err != nil {
+		return 0, err
+	}
+	defer stored.mutex.Unlock()
+
+	if stored.upload.ImageId != "" {
+		return stored.upload.Received, ErrUploadFinished
+	}
+	if offset < 0 || offset > stored.upload.Received {
+		return stored.upload.Received, fmt.Errorf("%w: %d > %d", ErrInvalidOffset, offset, stored.upload.Received)
+	}
+
+	file, err := os.OpenFile(stored.path, os.O_WRONLY, 0)
+	if err != nil {
+		return stored.upload.Received, fmt.Errorf("error while opening file: %v", err)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return stored.upload.Received, fmt.Errorf("error while truncating file: %v", err)
+	}
+	size, copyErr := io.Copy(io.NewOffsetWriter(file, offset), data)
+	closeErr := file.Close()
+
+	uploadStore.mutex.Lock()
+	stored.upload.Received = offset + size
+	stored.touchedAt = time.Now()
+	uploadStore.mutex.Unlock()
+
+	if copyErr != nil {
+		return stored.upload.Received, fmt.Errorf("error while writing to file: %w", copyErr)
+	}
+	if closeErr != nil {
+		return stored.upload.Received, fmt.Errorf("error while writing to file: %v", closeErr)
+	}
+	return stored.upload.Received, nil
+}
+
+func (uploadStore *DiskUploadStore) Open(uploadId string) (*Upload, UploadReader, error) {
+	stored, err := uploadStore.lock(uploadId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.upload.ImageId != "" {
+		stored.mutex.Unlock()
+		return nil, nil, ErrUploadFinished
+	}
+
+	file, err := os.Open(stored.path)
+	if err != nil {
+		stored.mutex.Unlock()
+		return nil, nil, fmt.Errorf("error while opening file: %v", err)
+	}
+	other := stored.upload
+	return &other, &diskUploadReader{File: file, store: uploadStore, stored: stored}, nil
+}
+
+func (uploadStore *DiskUploadStore) Delete(uploadId string) error {
+	stored, err := uploadStore.lock(uploadId)
+	if err != nil {
+		return err
+	}
+	defer stored.mutex.Unlock()
+
+	err = os.Remove(stored.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error while deleting file: %v", err)
+	}
+
+	uploadStore.mutex.Lock()
+	defer uploadStore.mutex.Unlock()
+	delete(uploadStore.uploads, uploadId)
+	return nil
+}
+
+// RemoveExpired removes the uploads that weren't written or finished within the ttl before now,
+// except those in use, and returns how many were removed
+func (uploadStore *DiskUploadStore) RemoveExpired(now time.Time) (int, error) {
+	expired := func(stored *diskUpload) bool {
+		return now.Sub(stored.touchedAt) > uploadStore.ttl
+	}
+
+	uploadStore.mutex.RLock()
+	var candidates []*diskUpload
+	for _, stored := range uploadStore.uploads {
+		if expired(stored) {
+			candidates = append(candidates, stored)
+		}
+	}
+	uploadStore.mutex.RUnlock()
+
+	removed := 0
+	for _, stored := range candidates {
+		if !stored.mutex.TryLock() {
+			// being written or read, so not left alone
+			continue
+		}
+		ok, err := uploadStore.removeExpired(stored, expired)
+		stored.mutex.Unlock()
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeExpired removes the locked upload if it is still stored and expired, and reports whether it did
+func (uploadStore *DiskUploadStore) removeExpired(stored *diskUpload, expired func(stored *diskUpload) bool) (bool, error) {
+	uploadStore.mutex.Lock()
+	defer uploadStore.mutex.Unlock()
+
+	if uploadStore.uploads[stored.upload.Id] != stored || !expired(stored) {
+		return false, nil
+	}
+	err := os.Remove(stored.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("error while deleting file: %v", err)
+	}
+	delete(uploadStore.uploads, stored.upload.Id)
+	return true, nil
+}
+
+// diskUploadReader releases the upload when it is closed
+type diskUploadReader struct {
+	*os.File
+	store  *DiskUploadStore
+	stored *diskUpload
+	once   sync.Once
+}
+
+func (reader *diskUploadReader) Finish(imageId string) error {
+	reader.store.mutex.Lock()
+	reader.stored.upload.ImageId = imageId
+	reader.stored.touchedAt = time.Now()
+	reader.store.mutex.Unlock()
+
+	err := os.Remove(reader.stored.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error while deleting file: %v", err)
+	}
+	return nil
+}
+
+func (reader *diskUploadReader) Close() error {
+	err := reader.File.Close()
+	reader.once.Do(reader.stored.mutex.Unlock)
+	return err
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUploadStore(t *testing.T, ttl time.Duration) *DiskUploadStore {
+	uploadStore, err := NewDiskUploadStore(t.TempDir(), ttl)
+	require.NoError(t, err)
+	return uploadStore
+}
+
+func TestDiskUploadStoreResume(t *testing.T) {
+	t.Parallel()
+
+	uploadStore := newTestUploadStore(t, time.Hour)
+	uploadId, err := uploadStore.Start("laptop", pb.ImageType_PNG, true)
+	require.NoError(t, err)
+
+	// the link drops after the first bytes
+	linkErr := errors.New("link dropped")
+	received, err := uploadStore.Write(uploadId, 0, io.MultiReader(strings.NewReader("front "), iotest.ErrReader(linkErr)))
+	require.ErrorIs(t, err, linkErr)
+	require.EqualValues(t, 6, received)
+
+	upload, err := uploadStore.Find(uploadId)
+	require.NoError(t, err)
+	require.Equal(t, Upload{Id: uploadId, LaptopId: "laptop", Type: pb.ImageType_PNG, Primary: true, Received: 6}, *upload)
+
+	_, err = uploadStore.Write(uploadId, 7, strings.NewReader("side"))
+	require.ErrorIs(t, err, ErrInvalidOffset)
+
+	// data sent again from an earlier offset replaces what was received after it
+	received, err = uploadStore.Write(uploadId, 5, strings.NewReader(" side"))
+	require.NoError(t, err)
+	require.EqualValues(t, 10, received)
+
+	upload, imageData, err := uploadStore.Open(uploadId)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, upload.Received)
+	data, err := io.ReadAll(imageData)
+	require.NoError(t, err)
+	require.Equal(t, "front side", string(data))
+	require.NoError(t, imageData.Close())
+
+	require.NoError(t, uploadStore.Delete(uploadId))
+	_, err = uploadStore.Find(uploadId)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = uploadStore.Write(uploadId, 0, strings.NewReader("again"))
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, uploadStore.Delete(uploadId), ErrNotFound)
+}
+
+func TestDiskUploadStoreFinish(t *testing.T) {
+	t.Parallel()
+
+	uploadStore := newTestUploadStore(t, time.Hour)
+	uploadId, err := uploadStore.Start("laptop", pb.ImageType_JPG, false)
+	require.NoError(t, err)
+	_, err = uploadStore.Write(uploadId, 0, strings.NewReader("image"))
+	require.NoError(t, err)
+
+	_, imageData, err := uploadStore.Open(uploadId)
+	require.NoError(t, err)
+	require.NoError(t, imageData.Finish("image-id"))
+	require.NoError(t, imageData.Close())
+	// closing again doesn't release the upload twice
+	imageData.Close()
+
+	upload, err := uploadStore.Find(uploadId)
+	require.NoError(t, err)
+	require.Equal(t, "image-id", upload.ImageId)
+	require.EqualValues(t, 5, upload.Received)
+
+	_, _, err = uploadStore.Open(uploadId)
+	require.ErrorIs(t, err, ErrUploadFinished)
+	_, err = uploadStore.Write(uploadId, 0, strings.NewReader("other"))
+	require.ErrorIs(t, err, ErrUploadFinished)
+	require.NoError(t, uploadStore.Delete(uploadId))
+}
+
+func TestDiskUploadStoreRemoveExpired(t *testing.T) {
+	t.Parallel()
+
+	uploadFolder := t.TempDir()
+	// files of a previous run are removed on startup
+	require.NoError(t, os.WriteFile(filepath.Join(uploadFolder, "stale.upload"), []byte("stale"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(uploadFolder, "laptop.jpg"), []byte("image"), 0o644))
+	uploadStore, err := NewDiskUploadStore(uploadFolder, time.Hour)
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(uploadFolder, "stale.upload"))
+	require.FileExists(t, filepath.Join(uploadFolder, "laptop.jpg"))
+
+	abandoned, err := uploadStore.Start("laptop", pb.ImageType_JPG, false)
+	require.NoError(t, err)
+	inUse, err := uploadStore.Start("laptop", pb.ImageType_JPG, false)
+	require.NoError(t, err)
+	finished, err := uploadStore.Start("laptop", pb.ImageType_JPG, false)
+	require.NoError(t, err)
+	_, imageData, err := uploadStore.Open(finished)
+	require.NoError(t, err)
+	require.NoError(t, imageData.Finish("image-id"))
+	require.NoError(t, imageData.Close())
+
+	removed, err := uploadStore.RemoveExpired(time.Now())
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	_, imageData, err = uploadStore.Open(inUse)
+	require.NoError(t, err)
+	removed, err = uploadStore.RemoveExpired(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	require.NoError(t, imageData.Close())
+
+	for _, uploadId := range []string{abandoned, finished} {
+		_, err := uploadStore.Find(uploadId)
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	_, err = uploadStore.Find(inUse)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(uploadFolder)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightUnits(t *testing.T) {
+	t.Parallel()
+
+	// 3 lb is about 1.36 kg
+	laptopLb := sample.NewLaptop()
+	laptopLb.Weight = &pb.Laptop_WeightLb{WeightLb: 3}
+	laptopKg := sample.NewLaptop()
+	laptopKg.Weight = &pb.Laptop_WeightKg{WeightKg: 1.4}
+
+	filterKg := &pb.Filter{MaxPriceUsd: 5000, MaxWeight: &pb.Filter_MaxWeightKg{MaxWeightKg: 1.38}}
+	require.True(t, isQualifiedLaptop(laptopLb, filterKg))
+	require.False(t, isQualifiedLaptop(laptopKg, filterKg))
+
+	filterLb := &pb.Filter{MaxPriceUsd: 5000, MaxWeight: &pb.Filter_MaxWeightLb{MaxWeightLb: 3.05}}
+	require.True(t, isQualifiedLaptop(laptopLb, filterLb))
+	require.False(t, isQualifiedLaptop(laptopKg, filterLb))
+
+	laptops := []*pb.Laptop{laptopKg, laptopLb}
+	sortLaptops(laptops, &pb.Sort{Field: pb.Sort_WEIGHT})
+	require.Equal(t, []*pb.Laptop{laptopLb, laptopKg}, laptops)
+}
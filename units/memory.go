@@ -0,0 +1,195 @@
+// Package units converts, compares, adds and formats pb.Memory sizes,
+// and converts laptop and filter weights to kilograms.
+//
+// A pb.Memory unit such as KILOBYTE is read either as a binary multiple (1 KB = 1024 bytes,
+// written KiB) or as a decimal one (1 KB = 1000 bytes, written kB), depending on the Convention.
+// Conversions to bits fit in 64 bits only up to 2 EiB, so comparisons and additions are done
+// on 128 bits and never lose precision.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/pokala15/pcbook/pb"
+)
+
+// Convention tells what multiple of bytes a unit is
+type Convention int
+
+const (
+	Binary  Convention = iota // powers of 1024, as for RAM
+	Decimal                   // powers of 1000, as for disk labels
+)
+
+// Default is the convention of laptops in this service, where a gigabyte of RAM
+// and a gigabyte of storage are both 2^30 bytes
+const Default = Binary
+
+var (
+	ErrUnknownUnit = errors.New("unknown memory unit")
+	ErrOverflow    = errors.New("memory size overflows 64 bits")
+)
+
+// units from the largest to the smallest
+var units = []pb.Memory_Unit{
+	pb.Memory_TERABYTE,
+	pb.Memory_GIGABYTE,
+	pb.Memory_MEGABYTE,
+	pb.Memory_KILOBYTE,
+	pb.Memory_BYTE,
+	pb.Memory_BIT,
+}
+
+var symbols = map[Convention]map[pb.Memory_Unit]string{
+	Binary: {
+		pb.Memory_BIT: "bit", pb.Memory_BYTE: "B", pb.Memory_KILOBYTE: "KiB",
+		pb.Memory_MEGABYTE: "MiB", pb.Memory_GIGABYTE: "GiB", pb.Memory_TERABYTE: "TiB",
+	},
+	Decimal: {
+		pb.Memory_BIT: "bit", pb.Memory_BYTE: "B", pb.Memory_KILOBYTE: "kB",
+		pb.Memory_MEGABYTE: "MB", pb.Memory_GIGABYTE: "GB", pb.Memory_TERABYTE: "TB",
+	},
+}
+
+// UnitBits returns the number of bits in one unit
+func (convention Convention) UnitBits(unit pb.Memory_Unit) (uint64, error) {
+	base := uint64(1024)
+	if convention == Decimal {
+		base = 1000
+	}
+
+	var multiples int
+	switch unit {
+	case pb.Memory_BIT:
+		return 1, nil
+	case pb.Memory_BYTE:
+		multiples = 0
+	case pb.Memory_KILOBYTE:
+		multiples = 1
+	case pb.Memory_MEGABYTE:
+		multiples = 2
+	case pb.Memory_GIGABYTE:
+		multiples = 3
+	case pb.Memory_TERABYTE:
+		multiples = 4
+	default:
+		return 0, fmt.Errorf("%w: %v", ErrUnknownUnit, unit)
+	}
+
+	unitBits := uint64(8)
+	for i := 0; i < multiples; i++ {
+		unitBits *= base
+	}
+	return unitBits, nil
+}
+
+// bits128 returns the size in bits as the high and low words of a 128 bits number.
+// A nil memory or a zero value is empty whatever its unit.
+func bits128(memory *pb.Memory, convention Convention) (hi uint64, lo uint64, err error) {
+	if memory.GetValue() == 0 {
+		return 0, 0, nil
+	}
+	unitBits, err := convention.UnitBits(memory.GetUnit())
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, lo = bits.Mul64(memory.GetValue(), unitBits)
+	return hi, lo, nil
+}
+
+// Bits returns the size of the memory in bits. Sizes that don't fit in 64 bits
+// return math.MaxUint64 with ErrOverflow, which still orders them correctly.
+func Bits(memory *pb.Memory, convention Convention) (uint64, error) {
+	hi, lo, err := bits128(memory, convention)
+	if err != nil {
+		return 0, err
+	}
+	if hi != 0 {
+		return math.MaxUint64, fmt.Errorf("%w: %d %v", ErrOverflow, memory.GetValue(), memory.GetUnit())
+	}
+	return lo, nil
+}
+
+// SaturatedBits returns the size of the memory in bits in the Default convention, saturated
+// beyond 64 bits and zero for an unknown unit, as a key to order memories by
+func SaturatedBits(memory *pb.Memory) uint64 {
+	bits, _ := Bits(memory, Default)
+	return bits
+}
+
+// Compare returns -1, 0 or +1 depending on whether a is smaller than, as large as or larger than b
+func Compare(a *pb.Memory, b *pb.Memory, convention Convention) (int, error) {
+	aHi, aLo, err := bits128(a, convention)
+	if err != nil {
+		return 0, err
+	}
+	bHi, bLo, err := bits128(b, convention)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case aHi < bHi || (aHi == bHi && aLo < bLo):
+		return -1, nil
+	case aHi > bHi || aLo > bLo:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Add returns the sum of the memories in the largest unit that represents it exactly
+func Add(a *pb.Memory, b *pb.Memory, convention Convention) (*pb.Memory, error) {
+	aHi, aLo, err := bits128(a, convention)
+	if err != nil {
+		return nil, err
+	}
+	bHi, bLo, err := bits128(b, convention)
+	if err != nil {
+		return nil, err
+	}
+
+	lo, carry := bits.Add64(aLo, bLo, 0)
+	hi, carry := bits.Add64(aHi, bHi, carry)
+	if carry != 0 {
+		return nil, ErrOverflow
+	}
+
+	for _, unit := range units {
+		unitBits, _ := convention.UnitBits(unit)
+		if hi >= unitBits {
+			// the value in this unit and in every smaller one doesn't fit in 64 bits
+			break
+		}
+		if value, remainder := bits.Div64(hi, lo, unitBits); remainder == 0 {
+			return &pb.Memory{Value: value, Unit: unit}, nil
+		}
+	}
+	return nil, ErrOverflow
+}
+
+// Format returns the size in the largest unit in which it is at least one, such as 1.5 GiB
+// or 1.61 GB, rounded to two decimals
+func Format(memory *pb.Memory, convention Convention) string {
+	hi, lo, err := bits128(memory, convention)
+	if err != nil {
+		return fmt.Sprintf("%d %v", memory.GetValue(), memory.GetUnit())
+	}
+	size := float64(hi)*math.Exp2(64) + float64(lo)
+	if size == 0 {
+		return "0 " + symbols[convention][pb.Memory_BYTE]
+	}
+
+	for _, unit := range units {
+		unitBits, _ := convention.UnitBits(unit)
+		if size >= float64(unitBits) || unit == pb.Memory_BIT {
+			value := strconv.FormatFloat(math.Round(size/float64(unitBits)*100)/100, 'f', -1, 64)
+			return value + " " + symbols[convention][unit]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func memory(value uint64, unit pb.Memory_Unit) *pb.Memory {
+	return &pb.Memory{Value: value, Unit: unit}
+}
+
+func TestBits(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		memory     *pb.Memory
+		convention Convention
+		bits       uint64
+		err        error
+	}{
+		{"nil", nil, Binary, 0, nil},
+		{"zero_unknown", &pb.Memory{}, Binary, 0, nil},
+		{"bits", memory(12, pb.Memory_BIT), Decimal, 12, nil},
+		{"binary_kilobyte", memory(1, pb.Memory_KILOBYTE), Binary, 8 * 1024, nil},
+		{"decimal_kilobyte", memory(1, pb.Memory_KILOBYTE), Decimal, 8 * 1000, nil},
+		{"binary_terabyte", memory(2, pb.Memory_TERABYTE), Binary, 2 * 8 << 40, nil},
+		{"decimal_gigabyte", memory(16, pb.Memory_GIGABYTE), Decimal, 16 * 8 * 1e9, nil},
+		{"unknown_unit", memory(16, pb.Memory_UNKNOWN), Binary, 0, ErrUnknownUnit},
+		{"overflow", memory(1<<21, pb.Memory_TERABYTE), Binary, math.MaxUint64, ErrOverflow},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bits, err := Bits(tc.memory, tc.convention)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.bits, bits)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		a      *pb.Memory
+		b      *pb.Memory
+		result int
+	}{
+		{"across_units", memory(1024, pb.Memory_MEGABYTE), memory(1, pb.Memory_GIGABYTE), 0},
+		{"smaller", memory(1023, pb.Memory_MEGABYTE), memory(1, pb.Memory_GIGABYTE), -1},
+		{"larger", memory(9, pb.Memory_BIT), memory(1, pb.Memory_BYTE), 1},
+		{"nil_is_empty", nil, memory(1, pb.Memory_BIT), -1},
+		// both sizes overflow 64 bits, and still differ
+		{"beyond_64_bits", memory(math.MaxUint64, pb.Memory_TERABYTE), memory(math.MaxUint64-1, pb.Memory_TERABYTE), 1},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := Compare(tc.a, tc.b, Binary)
+			require.NoError(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+
+	_, err := Compare(memory(1, pb.Memory_UNKNOWN), nil, Binary)
+	require.ErrorIs(t, err, ErrUnknownUnit)
+}
+
+func TestAdd(t *testing.T) {
+	t.Parallel()
+
+	sum, err := Add(memory(512, pb.Memory_GIGABYTE), memory(1536, pb.Memory_GIGABYTE), Binary)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), sum.Value)
+	require.Equal(t, pb.Memory_TERABYTE, sum.Unit)
+
+	sum, err = Add(memory(1, pb.Memory_GIGABYTE), memory(1, pb.Memory_BYTE), Decimal)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1_000_000_001), sum.Value)
+	require.Equal(t, pb.Memory_BYTE, sum.Unit)
+
+	sum, err = Add(nil, memory(3, pb.Memory_BIT), Binary)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), sum.Value)
+	require.Equal(t, pb.Memory_BIT, sum.Unit)
+
+	// the sum only fits in 64 bits as a number of terabytes
+	sum, err = Add(memory(math.MaxUint64-1, pb.Memory_TERABYTE), memory(1, pb.Memory_TERABYTE), Binary)
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), sum.Value)
+	require.Equal(t, pb.Memory_TERABYTE, sum.Unit)
+
+	_, err = Add(memory(math.MaxUint64, pb.Memory_TERABYTE), memory(1, pb.Memory_BYTE), Binary)
+	require.ErrorIs(t, err, ErrOverflow)
+
+	_, err = Add(memory(1, pb.Memory_UNKNOWN), nil, Binary)
+	require.ErrorIs(t, err, ErrUnknownUnit)
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		memory     *pb.Memory
+		convention Convention
+		text       string
+	}{
+		{nil, Binary, "0 B"},
+		{memory(4, pb.Memory_BIT), Binary, "4 bit"},
+		{memory(1536, pb.Memory_MEGABYTE), Binary, "1.5 GiB"},
+		{memory(16, pb.Memory_GIGABYTE), Decimal, "16 GB"},
+		{memory(1610, pb.Memory_MEGABYTE), Decimal, "1.61 GB"},
+		{memory(2048, pb.Memory_BYTE), Binary, "2 KiB"},
+		{memory(2048, pb.Memory_BYTE), Decimal, "2.05 kB"},
+		{memory(4096, pb.Memory_TERABYTE), Binary, "4096 TiB"},
+		{memory(8, pb.Memory_UNKNOWN), Binary, "8 UNKNOWN"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.text, Format(tc.memory, tc.convention))
+	}
+}
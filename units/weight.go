@@ -0,0 +1,31 @@
+package units
+
+import "github.com/pokala15/pcbook/pb"
+
+// KilogramsPerPound is the exact number of kilograms in an avoirdupois pound
+const KilogramsPerPound = 0.45359237
+
+// WeightKg returns the weight of the laptop in kilograms whichever unit it was given in,
+// or 0 if it has none
+func WeightKg(laptop *pb.Laptop) float64 {
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		return weight.WeightKg
+	case *pb.Laptop_WeightLb:
+		return weight.WeightLb * KilogramsPerPound
+	default:
+		return 0
+	}
+}
+
+// MaxWeightKg returns the maximum weight of the filter in kilograms, or 0 if there is none
+func MaxWeightKg(filter *pb.Filter) float64 {
+	switch weight := filter.GetMaxWeight().(type) {
+	case *pb.Filter_MaxWeightKg:
+		return weight.MaxWeightKg
+	case *pb.Filter_MaxWeightLb:
+		return weight.MaxWeightLb * KilogramsPerPound
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,21 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightKg(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 1.4, WeightKg(&pb.Laptop{Weight: &pb.Laptop_WeightKg{WeightKg: 1.4}}))
+	require.InDelta(t, 1.36, WeightKg(&pb.Laptop{Weight: &pb.Laptop_WeightLb{WeightLb: 3}}), 0.001)
+	require.Zero(t, WeightKg(&pb.Laptop{}))
+	require.Zero(t, WeightKg(nil))
+
+	require.Equal(t, 1.38, MaxWeightKg(&pb.Filter{MaxWeight: &pb.Filter_MaxWeightKg{MaxWeightKg: 1.38}}))
+	require.InDelta(t, 1.383, MaxWeightKg(&pb.Filter{MaxWeight: &pb.Filter_MaxWeightLb{MaxWeightLb: 3.05}}), 0.001)
+	require.Zero(t, MaxWeightKg(&pb.Filter{}))
+}
@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"errors"
+	"math"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+// ValidateFilter returns an *Error listing every problem of the filter, or nil if there is none.
+// A nil filter matches every laptop and is valid.
+func ValidateFilter(filter *pb.Filter) error {
+	var violations []Violation
+	v := validator{violations: &violations}
+
+	// NaN compares as false to every price and speed, so sorted indexes and scans would disagree
+	v.check("max_price_usd", isFinite(filter.GetMaxPriceUsd()), "must be a finite number")
+	v.check("min_cpu_ghz", isFinite(filter.GetMinCpuGhz()), "must be a finite number")
+
+	// sizes without a unit can't be compared to the memories of laptops
+	memories := []struct {
+		name   string
+		memory *pb.Memory
+	}{
+		{"min_ram", filter.GetMinRam()},
+		{"min_gpu_memory", filter.GetMinGpuMemory()},
+		{"min_ssd_capacity", filter.GetMinSsdCapacity()},
+		{"min_hdd_capacity", filter.GetMinHddCapacity()},
+	}
+	for _, m := range memories {
+		v.field(m.name).check("unit", m.memory.GetValue() == 0 || m.memory.GetUnit() != pb.Memory_UNKNOWN, "must be set")
+		if _, err := units.Bits(m.memory, units.Default); errors.Is(err, units.ErrOverflow) {
+			v.field(m.name).violate("is too large")
+		}
+	}
+
+	switch weight := filter.GetMaxWeight().(type) {
+	case *pb.Filter_MaxWeightKg:
+		v.check("max_weight_kg", weight.MaxWeightKg >= 0, "must not be negative")
+	case *pb.Filter_MaxWeightLb:
+		v.check("max_weight_lb", weight.MaxWeightLb >= 0, "must not be negative")
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFilter(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, ValidateFilter(nil))
+	require.NoError(t, ValidateFilter(&pb.Filter{
+		MinRam:         &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+		MinSsdCapacity: &pb.Memory{},
+	}))
+
+	err := ValidateFilter(&pb.Filter{
+		MaxPriceUsd:    math.NaN(),
+		MinCpuGhz:      math.Inf(1),
+		MinRam:         &pb.Memory{Value: 8},
+		MinGpuMemory:   &pb.Memory{Value: 1 << 30, Unit: pb.Memory_TERABYTE},
+		MinHddCapacity: &pb.Memory{Value: 1},
+		MaxWeight:      &pb.Filter_MaxWeightKg{MaxWeightKg: -1},
+	})
+	var validationErr *Error
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []Violation{
+		{Field: "max_price_usd", Description: "must be a finite number"},
+		{Field: "min_cpu_ghz", Description: "must be a finite number"},
+		{Field: "min_ram.unit", Description: "must be set"},
+		{Field: "min_gpu_memory", Description: "is too large"},
+		{Field: "min_hdd_capacity.unit", Description: "must be set"},
+		{Field: "max_weight_kg", Description: "must not be negative"},
+	}, validationErr.Violations)
+
+	err = ValidateFilter(&pb.Filter{MaxPriceUsd: math.Inf(-1), MinCpuGhz: math.NaN()})
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []Violation{
+		{Field: "max_price_usd", Description: "must be a finite number"},
+		{Field: "min_cpu_ghz", Description: "must be a finite number"},
+	}, validationErr.Violations)
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/pokala15/pcbook/pb"
+	_ "golang.org/x/image/webp"
+)
+
+// imageFormats are the names image.DecodeConfig gives to the formats of the supported image types
+var imageFormats = map[pb.ImageType]string{
+	pb.ImageType_JPG:  "jpeg",
+	pb.ImageType_PNG:  "png",
+	pb.ImageType_GIF:  "gif",
+	pb.ImageType_WEBP: "webp",
+}
+
+// ValidateImageInfo returns an *Error listing every problem of the info of an image to upload,
+// or nil if there is none
+func ValidateImageInfo(info *pb.ImageInfo) error {
+	var violations []Violation
+	v := validator{violations: &violations}
+
+	if info == nil {
+		v.violate("is required")
+		return &Error{Violations: violations}
+	}
+	v.check("laptop_id", info.GetLaptopId() != "", "is required")
+	validateImageType(v.field("image_type"), info.GetImageType())
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+func validateImageType(v validator, imageType pb.ImageType) {
+	if imageType == pb.ImageType_UNKNOWN {
+		v.violate("must be set")
+	} else if _, ok := imageFormats[imageType]; !ok {
+		v.violate("%v is not supported", imageType)
+	}
+}
+
+// ValidateImage reads the header of the image data and returns the width and height of the image,
+// or an *Error if the data is not an image of the declared type
+func ValidateImage(imageType pb.ImageType, data io.Reader) (width int, height int, err error) {
+	var violations []Violation
+	v := validator{violations: &violations}
+
+	validateImageType(v.field("image_type"), imageType)
+	if len(violations) > 0 {
+		return 0, 0, &Error{Violations: violations}
+	}
+
+	config, format, err := image.DecodeConfig(data)
+	switch {
+	case err == image.ErrFormat:
+		v.violate("is not a supported image")
+	case err != nil:
+		v.violate("is not a valid %s image: %v", format, err)
+	case format != imageFormats[imageType]:
+		v.field("image_type").violate("is %v but the data is a %s image", imageType, format)
+	}
+	if len(violations) > 0 {
+		return 0, 0, &Error{Violations: violations}
+	}
+	return config.Width, config.Height, nil
+}
@@ -0,0 +1,133 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+// newWebpHeader returns the header of a lossless WEBP image, which is all that is decoded
+func newWebpHeader(width int, height int) []byte {
+	vp8l := binary.LittleEndian.AppendUint32([]byte{0x2f}, uint32(width-1)|uint32(height-1)<<14)
+	chunk := append([]byte("VP8L"), binary.LittleEndian.AppendUint32(nil, uint32(len(vp8l)))...)
+	chunk = append(chunk, vp8l...)
+	if len(vp8l)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	header := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(chunk)))...)
+	header = append(header, "WEBP"...)
+	return append(header, chunk...)
+}
+
+func TestValidateImage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		imageType  pb.ImageType
+		data       []byte
+		width      int
+		height     int
+		violations []string
+	}{
+		{
+			name:      "jpg",
+			imageType: pb.ImageType_JPG,
+			data:      sample.NewImage(pb.ImageType_JPG, 16, 9),
+			width:     16,
+			height:    9,
+		},
+		{
+			name:      "png",
+			imageType: pb.ImageType_PNG,
+			data:      sample.NewImage(pb.ImageType_PNG, 4, 3),
+			width:     4,
+			height:    3,
+		},
+		{
+			name:      "gif",
+			imageType: pb.ImageType_GIF,
+			data:      sample.NewImage(pb.ImageType_GIF, 1, 1),
+			width:     1,
+			height:    1,
+		},
+		{
+			name:      "webp",
+			imageType: pb.ImageType_WEBP,
+			data:      newWebpHeader(5, 7),
+			width:     5,
+			height:    7,
+		},
+		{
+			name:       "mismatched_type",
+			imageType:  pb.ImageType_JPG,
+			data:       newWebpHeader(5, 7),
+			violations: []string{"image_type"},
+		},
+		{
+			name:       "unknown_type",
+			imageType:  pb.ImageType_UNKNOWN,
+			data:       sample.NewImage(pb.ImageType_PNG, 4, 3),
+			violations: []string{"image_type"},
+		},
+		{
+			name:       "unsupported_type",
+			imageType:  pb.ImageType(99),
+			data:       sample.NewImage(pb.ImageType_PNG, 4, 3),
+			violations: []string{"image_type"},
+		},
+		{
+			name:       "not_an_image",
+			imageType:  pb.ImageType_PNG,
+			data:       []byte("%PDF-1.7"),
+			violations: []string{""},
+		},
+		{
+			name:       "truncated_header",
+			imageType:  pb.ImageType_JPG,
+			data:       sample.NewImage(pb.ImageType_JPG, 4, 3)[:8],
+			violations: []string{""},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			width, height, err := ValidateImage(tc.imageType, bytes.NewReader(tc.data))
+			if len(tc.violations) == 0 {
+				require.NoError(t, err)
+				require.Equal(t, tc.width, width)
+				require.Equal(t, tc.height, height)
+				return
+			}
+
+			var validationErr *Error
+			require.ErrorAs(t, err, &validationErr)
+			var fields []string
+			for _, violation := range validationErr.Violations {
+				fields = append(fields, violation.Field)
+			}
+			require.Equal(t, tc.violations, fields)
+		})
+	}
+}
+
+func TestValidateImageInfo(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, ValidateImageInfo(&pb.ImageInfo{LaptopId: "laptop", ImageType: pb.ImageType_WEBP}))
+
+	err := ValidateImageInfo(&pb.ImageInfo{})
+	var validationErr *Error
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "laptop_id is required; image_type must be set", err.Error())
+
+	require.ErrorAs(t, ValidateImageInfo(nil), &validationErr)
+}
@@ -0,0 +1,176 @@
+// Package validation checks that laptops make sense before they are stored,
+// that search filters can be evaluated, and that uploaded images are what they claim to be.
+//
+// Every problem is reported as a violation of a field, named by its path from the message
+// such as `cpu.max_ghz` or `storages[1].memory.unit`, so that clients can fix all of them at once.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/units"
+)
+
+// firstReleaseYear is the year of the first laptops, releases can't be older
+const firstReleaseYear = 1981
+
+// Violation is a problem with a field of a message, or with the message itself if Field is empty
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Error lists every violation found in a message
+type Error struct {
+	Violations []Violation
+}
+
+func (err *Error) Error() string {
+	problems := make([]string, len(err.Violations))
+	for i, violation := range err.Violations {
+		problems[i] = strings.TrimPrefix(violation.Field+" "+violation.Description, " ")
+	}
+	return strings.Join(problems, "; ")
+}
+
+// validator collects the violations of the fields under a path
+type validator struct {
+	violations *[]Violation
+	path       string
+}
+
+func (v validator) field(name string) validator {
+	if v.path == "" {
+		return validator{violations: v.violations, path: name}
+	}
+	return validator{violations: v.violations, path: v.path + "." + name}
+}
+
+func (v validator) index(i int) validator {
+	return validator{violations: v.violations, path: fmt.Sprintf("%s[%d]", v.path, i)}
+}
+
+func (v validator) violate(format string, args ...any) {
+	*v.violations = append(*v.violations, Violation{
+		Field:       v.path,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// check records a violation of the field unless ok
+func (v validator) check(name string, ok bool, format string, args ...any) {
+	if !ok {
+		v.field(name).violate(format, args...)
+	}
+}
+
+// required records a violation of the field if it is missing and reports whether it is present
+func (v validator) required(name string, present bool) bool {
+	if !present {
+		v.field(name).violate("is required")
+	}
+	return present
+}
+
+// ValidateLaptop returns an *Error listing every problem of the laptop, or nil if there is none.
+// The id is not checked, it may be assigned by the server.
+func ValidateLaptop(laptop *pb.Laptop) error {
+	var violations []Violation
+	v := validator{violations: &violations}
+
+	if laptop == nil {
+		v.violate("is required")
+		return &Error{Violations: violations}
+	}
+
+	v.check("brand", laptop.GetBrand() != "", "is required")
+	v.check("name", laptop.GetName() != "", "is required")
+	if v.required("cpu", laptop.GetCpu() != nil) {
+		validateCpu(v.field("cpu"), laptop.GetCpu())
+	}
+	if v.required("ram", laptop.GetRam() != nil) {
+		validateMemory(v.field("ram"), laptop.GetRam())
+	}
+	for i, gpu := range laptop.GetGpus() {
+		validateGpu(v.field("gpus").index(i), gpu)
+	}
+	if v.required("storages", len(laptop.GetStorages()) > 0) {
+		for i, storage := range laptop.GetStorages() {
+			validateStorage(v.field("storages").index(i), storage)
+		}
+	}
+	if v.required("screen", laptop.GetScreen() != nil) {
+		validateScreen(v.field("screen"), laptop.GetScreen())
+	}
+	if v.required("keyboard", laptop.GetKeyboard() != nil) {
+		v.field("keyboard").check("layout", laptop.GetKeyboard().GetLayout() != pb.Keyboard_UNKNOWN, "must be set")
+	}
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		v.check("weight_kg", weight.WeightKg > 0, "must be positive")
+	case *pb.Laptop_WeightLb:
+		v.check("weight_lb", weight.WeightLb > 0, "must be positive")
+	default:
+		v.field("weight").violate("is required")
+	}
+	v.check("price_usd", laptop.GetPriceUsd() > 0, "must be positive")
+
+	lastReleaseYear := uint32(time.Now().Year() + 1)
+	v.check("release_year", laptop.GetReleaseYear() >= firstReleaseYear && laptop.GetReleaseYear() <= lastReleaseYear,
+		"must be between %d and %d", firstReleaseYear, lastReleaseYear)
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+func validateCpu(v validator, cpu *pb.CPU) {
+	v.check("brand", cpu.GetBrand() != "", "is required")
+	v.check("name", cpu.GetName() != "", "is required")
+	v.check("number_cores", cpu.GetNumberCores() > 0, "must be positive")
+	v.check("number_threads", cpu.GetNumberThreads() >= cpu.GetNumberCores(),
+		"must be at least the number of cores (%d)", cpu.GetNumberCores())
+	v.check("min_ghz", cpu.GetMinGhz() > 0, "must be positive")
+	v.check("max_ghz", cpu.GetMaxGhz() >= cpu.GetMinGhz(), "must be at least min_ghz (%g)", cpu.GetMinGhz())
+}
+
+func validateGpu(v validator, gpu *pb.GPU) {
+	v.check("brand", gpu.GetBrand() != "", "is required")
+	v.check("name", gpu.GetName() != "", "is required")
+	v.check("min_ghz", gpu.GetMinGhz() > 0, "must be positive")
+	v.check("max_ghz", gpu.GetMaxGhz() >= gpu.GetMinGhz(), "must be at least min_ghz (%g)", gpu.GetMinGhz())
+	if v.required("memory", gpu.GetMemory() != nil) {
+		validateMemory(v.field("memory"), gpu.GetMemory())
+	}
+}
+
+func validateStorage(v validator, storage *pb.Storage) {
+	v.check("driver", storage.GetDriver() != pb.Storage_UNKNOWN, "must be set")
+	if v.required("memory", storage.GetMemory() != nil) {
+		validateMemory(v.field("memory"), storage.GetMemory())
+	}
+}
+
+func validateMemory(v validator, memory *pb.Memory) {
+	v.check("value", memory.GetValue() > 0, "must be positive")
+	v.check("unit", memory.GetUnit() != pb.Memory_UNKNOWN, "must be set")
+	if _, err := units.Bits(memory, units.Default); errors.Is(err, units.ErrOverflow) {
+		v.violate("is too large")
+	}
+}
+
+func validateScreen(v validator, screen *pb.Screen) {
+	v.check("size_inch", screen.GetSizeInch() > 0, "must be positive")
+	if v.required("resolution", screen.GetResolution() != nil) {
+		resolution := v.field("resolution")
+		resolution.check("width", screen.GetResolution().GetWidth() > 0, "must be positive")
+		resolution.check("height", screen.GetResolution().GetHeight() > 0, "must be positive")
+	}
+	v.check("panel", screen.GetPanel() != pb.Screen_UNKNOWN, "must be set")
+}
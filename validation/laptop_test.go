@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/pokala15/pcbook/pb"
+	"github.com/pokala15/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLaptop(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		laptop     func(laptop *pb.Laptop)
+		violations []string
+	}{
+		{
+			name:   "sample",
+			laptop: func(laptop *pb.Laptop) {},
+		},
+		{
+			name:       "missing_cpu",
+			laptop:     func(laptop *pb.Laptop) { laptop.Cpu = nil },
+			violations: []string{"cpu"},
+		},
+		{
+			name: "cpu_ranges",
+			laptop: func(laptop *pb.Laptop) {
+				laptop.Cpu.NumberCores = 8
+				laptop.Cpu.NumberThreads = 4
+				laptop.Cpu.MinGhz = 3
+				laptop.Cpu.MaxGhz = 2
+			},
+			violations: []string{"cpu.number_threads", "cpu.max_ghz"},
+		},
+		{
+			name: "unknown_enums",
+			laptop: func(laptop *pb.Laptop) {
+				laptop.Storages[1].Driver = pb.Storage_UNKNOWN
+				laptop.Gpus[0].Memory.Unit = pb.Memory_UNKNOWN
+				laptop.Screen.Panel = pb.Screen_UNKNOWN
+				laptop.Keyboard.Layout = pb.Keyboard_UNKNOWN
+			},
+			violations: []string{"gpus[0].memory.unit", "storages[1].driver", "screen.panel", "keyboard.layout"},
+		},
+		{
+			name:       "ram_overflow",
+			laptop:     func(laptop *pb.Laptop) { laptop.Ram = &pb.Memory{Value: 1 << 40, Unit: pb.Memory_TERABYTE} },
+			violations: []string{"ram"},
+		},
+		{
+			name: "weight_and_price",
+			laptop: func(laptop *pb.Laptop) {
+				laptop.Weight = &pb.Laptop_WeightLb{WeightLb: -1}
+				laptop.PriceUsd = 0
+			},
+			violations: []string{"weight_lb", "price_usd"},
+		},
+		{
+			name: "missing_everything",
+			laptop: func(laptop *pb.Laptop) {
+				*laptop = pb.Laptop{}
+			},
+			violations: []string{
+				"brand", "name", "cpu", "ram", "storages", "screen", "keyboard", "weight", "price_usd", "release_year",
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptop := sample.NewLaptop()
+			tc.laptop(laptop)
+
+			err := ValidateLaptop(laptop)
+			if len(tc.violations) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var validationErr *Error
+			require.ErrorAs(t, err, &validationErr)
+			var fields []string
+			for _, violation := range validationErr.Violations {
+				fields = append(fields, violation.Field)
+			}
+			require.Equal(t, tc.violations, fields)
+		})
+	}
+}